@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -90,7 +91,7 @@ func (s *server) handleGoogleCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := model.FindUserByEmail(s.db, googleAcctInfo.Email)
+	user, err := s.store.FindUserByEmail(googleAcctInfo.Email)
 	if err != nil {
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
@@ -100,7 +101,7 @@ func (s *server) handleGoogleCallback(w http.ResponseWriter, r *http.Request) {
 		// User does not exist in database.
 		if isDxeEmail(googleAcctInfo.Email) {
 			// User has a DxE email address, so just create an account for them.
-			user, err = model.CreateAndReturnUser(s.db, model.User{
+			user, err = model.CreateAndReturnUser(s.store, model.User{
 				Name:   googleAcctInfo.Name,
 				Email:  googleAcctInfo.Email,
 				Active: true,
@@ -117,7 +118,7 @@ func (s *server) handleGoogleCallback(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	err = model.UpdateUserLastLoggedIn(s.db, user)
+	err = s.store.UpdateUserLastLoggedIn(user)
 	if err != nil {
 		http.Error(w, "Failed to update user last login time: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -187,14 +188,9 @@ func userCtx(next http.Handler) http.Handler {
 			return
 		}
 
-		jsonBody, err := json.Marshal(claims["user"])
+		user, err := userFromClaims(claims)
 		if err != nil {
-			http.Error(w, "Failed to marshal user object.", http.StatusInternalServerError)
-			return
-		}
-		var user model.User
-		if err := json.Unmarshal(jsonBody, &user); err != nil {
-			http.Error(w, "Failed to unmarshal user object.", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -204,6 +200,19 @@ func userCtx(next http.Handler) http.Handler {
 	})
 }
 
+// userFromClaims decodes the user that issueJWTToken stores in a token.
+func userFromClaims(claims map[string]interface{}) (model.User, error) {
+	var user model.User
+	jsonBody, err := json.Marshal(claims["user"])
+	if err != nil {
+		return user, errors.New("failed to marshal user object")
+	}
+	if err := json.Unmarshal(jsonBody, &user); err != nil {
+		return user, errors.New("failed to unmarshal user object")
+	}
+	return user, nil
+}
+
 func mustGetUserFromCtx(ctx context.Context) model.User {
 	user, ok := ctx.Value("user").(model.User)
 	if !ok {
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"time"
+
+	"github.com/dxe/url-shortcuts-go/model"
+	"github.com/patrickmn/go-cache"
+)
+
+// RedirectCache caches shortcut lookups for the redirect handler. A cached
+// zero-value Shortcut records that no shortcut exists for the code.
+//
+// Mutations invalidate the affected codes, so entries only go stale when they
+// are changed through another replica. A cache shared between replicas can be
+// plugged in by implementing this interface.
+type RedirectCache interface {
+	Get(code string) (shortcut model.Shortcut, found bool)
+	Set(code string, shortcut model.Shortcut)
+	Invalidate(codes ...string)
+}
+
+// memoryRedirectCache is an in-process RedirectCache. Unknown codes are kept
+// separately from known ones so that they can expire sooner.
+type memoryRedirectCache struct {
+	shortcuts *cache.Cache
+	missing   *cache.Cache
+}
+
+func newMemoryRedirectCache(ttl, missingTTL time.Duration) *memoryRedirectCache {
+	return &memoryRedirectCache{
+		shortcuts: cache.New(ttl, 5*time.Minute),
+		missing:   cache.New(missingTTL, 5*time.Minute),
+	}
+}
+
+func (c *memoryRedirectCache) Get(code string) (model.Shortcut, bool) {
+	if _, found := c.missing.Get(code); found {
+		return model.Shortcut{}, true
+	}
+	if v, found := c.shortcuts.Get(code); found {
+		return v.(model.Shortcut), true
+	}
+	return model.Shortcut{}, false
+}
+
+func (c *memoryRedirectCache) Set(code string, shortcut model.Shortcut) {
+	if shortcut.ID == 0 {
+		c.missing.SetDefault(code, struct{}{})
+		return
+	}
+	c.shortcuts.SetDefault(code, shortcut)
+}
+
+func (c *memoryRedirectCache) Invalidate(codes ...string) {
+	for _, code := range codes {
+		c.shortcuts.Delete(code)
+		c.missing.Delete(code)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/dxe/url-shortcuts-go/model"
+)
+
+// slowStore lets a test change a shortcut while a lookup is between reading
+// it and caching it.
+type slowStore struct {
+	model.Store
+	read    chan struct{}
+	release chan struct{}
+}
+
+func (s slowStore) GetShortcutByCode(code string) (model.Shortcut, error) {
+	shortcut, err := s.Store.GetShortcutByCode(code)
+	s.read <- struct{}{}
+	<-s.release
+	return shortcut, err
+}
+
+func TestLookupDoesNotCacheChangedShortcut(t *testing.T) {
+	store := slowStore{
+		Store:   model.NewMemoryStore(),
+		read:    make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	s := newTestServer(t, store)
+	id, err := store.InsertShortcut(model.Shortcut{ShortcutSettings: model.ShortcutSettings{Code: "join", URL: "https://example.com/old"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	looked := make(chan model.Shortcut)
+	go func() {
+		shortcut, err := s.lookupShortcut("join")
+		if err != nil {
+			t.Error(err)
+		}
+		looked <- shortcut
+	}()
+	<-store.read
+
+	if err := store.UpdateShortcut(model.Shortcut{ID: int(id), ShortcutSettings: model.ShortcutSettings{Code: "join", URL: "https://example.com/new"}}); err != nil {
+		t.Fatal(err)
+	}
+	s.invalidateShortcuts("join")
+	close(store.release)
+
+	if shortcut := <-looked; shortcut.URL != "https://example.com/old" {
+		t.Errorf("lookup that started before the update = %q", shortcut.URL)
+	}
+	if _, found := s.redirectCache.Get("join"); found {
+		t.Error("shortcut read before the update was cached")
+	}
+
+	go func() { <-store.read }()
+	shortcut, err := s.lookupShortcut("join")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shortcut.URL != "https://example.com/new" {
+		t.Errorf("lookup after the update = %q", shortcut.URL)
+	}
+}
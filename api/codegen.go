@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/dxe/url-shortcuts-go/model"
+)
+
+// defaultCodeAlphabet leaves out characters that are easily mistaken for each
+// other (0/o, 1/l/i). Codes are case folded, so only lower case is used.
+const defaultCodeAlphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+
+// maxCodeAttempts is how many generated codes are tried before giving up on
+// creating a shortcut.
+const maxCodeAttempts = 5
+
+// codeGenerator generates random shortcut codes.
+type codeGenerator struct {
+	alphabet []rune
+	length   int
+}
+
+func newCodeGenerator(alphabet string, length int) (codeGenerator, error) {
+	if length < 1 || length > maxCodeLength {
+		return codeGenerator{}, fmt.Errorf("code length must be between 1 and %v", maxCodeLength)
+	}
+	seen := make(map[rune]bool)
+	for _, c := range alphabet {
+		if seen[c] {
+			return codeGenerator{}, fmt.Errorf("code alphabet contains %q more than once", c)
+		}
+		seen[c] = true
+		if s := string(c); !codePattern.MatchString(s) || model.NormalizeCode(s) != s {
+			return codeGenerator{}, fmt.Errorf("code alphabet contains %q, which can't be used in codes", c)
+		}
+	}
+	if len(seen) < 2 {
+		return codeGenerator{}, fmt.Errorf("code alphabet must contain at least two characters")
+	}
+	return codeGenerator{alphabet: []rune(alphabet), length: length}, nil
+}
+
+// Generate returns a random code that passes validation. It may still be taken.
+func (g codeGenerator) Generate() (string, error) {
+	max := big.NewInt(int64(len(g.alphabet)))
+	for {
+		var b strings.Builder
+		for i := 0; i < g.length; i++ {
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return "", fmt.Errorf("failed to generate code: %w", err)
+			}
+			b.WriteRune(g.alphabet[n.Int64()])
+		}
+
+		var errs validationErrors
+		if validateCode(b.String(), &errs); len(errs) == 0 {
+			return b.String(), nil
+		}
+	}
+}
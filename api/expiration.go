@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+)
+
+// handleExpired responds to a request for a shortcut that has expired.
+func (s *server) handleExpired(w http.ResponseWriter, r *http.Request) {
+	if s.expiredURL == "" {
+		http.Error(w, "This link has expired.", http.StatusGone)
+		return
+	}
+	http.Redirect(w, r, s.expiredURL, http.StatusFound)
+}
+
+// sweepExpiredShortcuts periodically marks shortcuts whose expiration time has
+// passed, until ctx is cancelled. Redirects check the expiration time
+// themselves; the flag is there so expired shortcuts can be found and listed.
+func (s *server) sweepExpiredShortcuts(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n, err := s.store.MarkExpiredShortcuts(now)
+			if err != nil {
+				log.Printf("Failed to mark expired shortcuts: %v", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("Marked %v shortcuts as expired", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dxe/url-shortcuts-go/model"
+)
+
+// Fallback modes, for requests for codes that don't exist.
+const (
+	// fallbackRedirect redirects to the fallback URL with the code appended.
+	fallbackRedirect = "redirect"
+	// fallbackPage shows a 404 page listing similar codes.
+	fallbackPage = "page"
+	// fallbackNotFound responds with a plain 404.
+	fallbackNotFound = "notfound"
+)
+
+func validateFallbackMode(mode string) error {
+	switch mode {
+	case fallbackRedirect, fallbackPage, fallbackNotFound:
+		return nil
+	}
+	return fmt.Errorf("fallback mode must be one of %v, %v or %v", fallbackRedirect, fallbackPage, fallbackNotFound)
+}
+
+// parseFallbackURL parses the URL unknown codes redirect to in redirect mode.
+// It must be absolute, since codes are appended to its path.
+func parseFallbackURL(raw string) (*url.URL, error) {
+	fallback, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fallback URL: %v", err)
+	}
+	if (fallback.Scheme != "http" && fallback.Scheme != "https") || fallback.Host == "" {
+		return nil, fmt.Errorf("fallback URL must be an absolute http or https URL, got %q", raw)
+	}
+	return fallback, nil
+}
+
+// fallbackTarget returns the fallback URL with path appended as one or more
+// escaped segments of its path.
+func fallbackTarget(fallback *url.URL, path string) *url.URL {
+	target := *fallback
+	target.Path = strings.TrimSuffix(fallback.Path, "/") + "/" + path
+	target.RawPath = strings.TrimSuffix(fallback.EscapedPath(), "/") + "/" + escapePath(path)
+	return &target
+}
+
+// handleMissing responds to a request for a code that doesn't exist. If the
+// code is a single typo away from an existing one, it redirects there.
+// Otherwise, the fallback mode applies. Similar codes are suggested on the 404
+// page, which notfound mode also shows if there are any; redirect mode always
+// sends visitors to the fallback URL. path is the code as requested, which is
+// passed on to the fallback URL as it is, since codes elsewhere may be
+// case-sensitive.
+func (s *server) handleMissing(w http.ResponseWriter, r *http.Request, path string) {
+	code := model.NormalizeCode(path)
+	s.misses.Record(code)
+
+	if s.fallbackMode == fallbackPage || s.fuzzyMatching {
+		matches, err := s.codeIndex.Match(code, maxCodeSuggestionsOnMiss)
+		if err != nil {
+			log.Printf("Failed to find codes similar to %q: %v", code, err)
+		}
+
+		if match, ok := closeMatch(code, matches); ok && s.fuzzyMatching {
+			target := url.URL{Path: "/" + match, RawQuery: r.URL.RawQuery}
+			http.Redirect(w, r, target.String(), http.StatusFound)
+			return
+		}
+
+		if s.fallbackMode == fallbackPage || (s.fallbackMode == fallbackNotFound && len(matches) > 0) {
+			similar := make([]string, len(matches))
+			for i, m := range matches {
+				similar[i] = m.Code
+			}
+			renderPage(w, http.StatusNotFound, "notfound.html", map[string]interface{}{
+				"Host":    s.shortLinkHost(),
+				"Code":    code,
+				"Similar": similar,
+			})
+			return
+		}
+	}
+
+	switch s.fallbackMode {
+	case fallbackNotFound:
+		http.NotFound(w, r)
+	default:
+		http.Redirect(w, r, fallbackTarget(s.fallbackURL, path).String(), http.StatusFound)
+	}
+}
+
+// missRecorder counts requests for codes that don't exist and periodically
+// adds the counts to the store. Codes that couldn't be created anyway, such as
+// /favicon.ico, aren't counted.
+type missRecorder struct {
+	store model.Store
+	// maxCodes limits how many distinct codes are counted between flushes,
+	// so that scanners trying random paths can't use up memory.
+	maxCodes int
+
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func newMissRecorder(store model.Store, maxCodes int) *missRecorder {
+	return &missRecorder{
+		store:    store,
+		maxCodes: maxCodes,
+		counts:   make(map[string]int64),
+	}
+}
+
+func (m *missRecorder) Record(code string) {
+	var errs validationErrors
+	if validateCode(code, &errs); len(errs) > 0 {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.counts[code]; ok || len(m.counts) < m.maxCodes {
+		m.counts[code]++
+	}
+}
+
+// Flush writes the counts recorded since the last flush.
+func (m *missRecorder) Flush() {
+	m.mu.Lock()
+	counts := m.counts
+	m.counts = make(map[string]int64)
+	m.mu.Unlock()
+
+	if len(counts) == 0 {
+		return
+	}
+	if err := m.store.RecordMissedCodes(counts, time.Now()); err != nil {
+		log.Printf("Failed to record %v missed codes: %v", len(counts), err)
+	}
+}
+
+// Run flushes the counts every interval until ctx is cancelled.
+func (m *missRecorder) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Flush()
+		}
+	}
+}
+
+func (s *server) getMissedCodes(w http.ResponseWriter, r *http.Request) {
+	codes, err := s.store.ListMissedCodes(100)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"codes": codes,
+	})
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dxe/url-shortcuts-go/model"
+)
+
+func TestParseFallbackURL(t *testing.T) {
+	for _, raw := range []string{"https://example.org", "http://example.org/", "https://example.org/missing?ref=dxe-io"} {
+		if _, err := parseFallbackURL(raw); err != nil {
+			t.Errorf("parseFallbackURL(%q) = %v", raw, err)
+		}
+	}
+	for _, raw := range []string{"", "example.org", "/missing/", "javascript:alert(1)", "https://"} {
+		if _, err := parseFallbackURL(raw); err == nil {
+			t.Errorf("parseFallbackURL(%q) succeeded", raw)
+		}
+	}
+}
+
+func TestFallbackTarget(t *testing.T) {
+	tests := []struct {
+		fallback, path, want string
+	}{
+		{"https://example.org/", "SomeThing", "https://example.org/SomeThing"},
+		{"https://example.org", "join", "https://example.org/join"},
+		{"https://example.org", "@evil.com/x", "https://example.org/@evil.com/x"},
+		{"https://example.org", "a b?q=1", "https://example.org/a%20b%3Fq=1"},
+		{"https://example.org/missing/?ref=dxe-io", "join", "https://example.org/missing/join?ref=dxe-io"},
+		{"https://example.org/a%2Fb", "join", "https://example.org/a%2Fb/join"},
+	}
+	for _, test := range tests {
+		fallback, err := parseFallbackURL(test.fallback)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fallbackTarget(fallback, test.path).String(); got != test.want {
+			t.Errorf("fallbackTarget(%q, %q) = %q, want %q", test.fallback, test.path, got, test.want)
+		}
+	}
+}
+
+func TestMissingCodeRedirectsToFallback(t *testing.T) {
+	s := newTestServer(t, model.NewMemoryStore())
+	for path, want := range map[string]string{
+		"/SomeThing":   "https://example.org/SomeThing",
+		"/@evil.com/x": "https://example.org/@evil.com/x",
+		"/a%20b%3Fq=1": "https://example.org/a%20b%3Fq=1",
+	} {
+		w := serve(s, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusFound || w.Header().Get("Location") != want {
+			t.Errorf("GET %v = %v %q, want %q", path, w.Code, w.Header().Get("Location"), want)
+		}
+	}
+}
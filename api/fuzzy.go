@@ -0,0 +1,180 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dxe/url-shortcuts-go/model"
+)
+
+const maxCodeSuggestionsOnMiss = 10
+
+// codeMatch is an existing code that is similar to a requested one.
+type codeMatch struct {
+	Code     string
+	Distance int
+}
+
+// codeIndex keeps the codes of all active shortcuts in memory, so that codes
+// similar to a mistyped one can be found without querying the database on
+// every miss. It is rebuilt after maxAge, or on the next lookup after
+// Invalidate is called.
+type codeIndex struct {
+	store  model.Store
+	maxAge time.Duration
+
+	mu      sync.Mutex
+	codes   []string
+	builtAt time.Time
+}
+
+func newCodeIndex(store model.Store, maxAge time.Duration) *codeIndex {
+	return &codeIndex{store: store, maxAge: maxAge}
+}
+
+// Invalidate marks the index as out of date after shortcuts have changed.
+func (ix *codeIndex) Invalidate() {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.builtAt = time.Time{}
+}
+
+func (ix *codeIndex) current() ([]string, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	if time.Since(ix.builtAt) < ix.maxAge {
+		return ix.codes, nil
+	}
+
+	shortcuts, _, err := ix.store.ListShortcuts(model.ListShortcutOptions{})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	codes := make([]string, 0, len(shortcuts))
+	for _, s := range shortcuts {
+		// Protected codes are never suggested, so that they can't be found
+		// by guessing.
+		if s.StateAt(now) == model.ShortcutActive && s.Visibility == model.VisibilityPublic {
+			codes = append(codes, s.Code)
+		}
+	}
+
+	ix.codes, ix.builtAt = codes, now
+	return codes, nil
+}
+
+// Match returns up to n codes similar to code, closest first. Codes are similar
+// if they are within a few edits of each other, depending on the code's
+// length, or if one is a prefix of the other.
+func (ix *codeIndex) Match(code string, n int) ([]codeMatch, error) {
+	codes, err := ix.current()
+	if err != nil {
+		return nil, err
+	}
+
+	maxDistance := maxEditDistance(code)
+	var matches []codeMatch
+	for _, c := range codes {
+		d := levenshtein(code, c, maxDistance)
+		if d <= maxDistance || isCodePrefix(code, c) || isCodePrefix(c, code) {
+			matches = append(matches, codeMatch{Code: c, Distance: d})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Distance != matches[j].Distance {
+			return matches[i].Distance < matches[j].Distance
+		}
+		return matches[i].Code < matches[j].Code
+	})
+	if len(matches) > n {
+		matches = matches[:n]
+	}
+	return matches, nil
+}
+
+// closeMatch returns the only match that is a single edit away, if there is
+// exactly one. Very short codes never match closely, since almost any typo
+// turns them into another valid code.
+func closeMatch(code string, matches []codeMatch) (string, bool) {
+	if len([]rune(code)) < 4 {
+		return "", false
+	}
+	var found []string
+	for _, m := range matches {
+		if m.Distance == 1 {
+			found = append(found, m.Code)
+		}
+	}
+	if len(found) != 1 {
+		return "", false
+	}
+	return found[0], true
+}
+
+func maxEditDistance(code string) int {
+	switch n := len([]rune(code)); {
+	case n <= 4:
+		return 1
+	case n <= 8:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// isCodePrefix reports whether prefix is a meaningful prefix of code, e.g.
+// "volunteer" for "volunteer-la".
+func isCodePrefix(prefix, code string) bool {
+	return len(prefix) >= 3 && len(prefix) < len(code) && strings.HasPrefix(code, prefix)
+}
+
+// levenshtein returns the edit distance between a and b. It stops early once
+// the distance is known to exceed max, returning max+1.
+func levenshtein(a, b string, max int) int {
+	ra, rb := []rune(a), []rune(b)
+	if diff := len(ra) - len(rb); diff > max || -diff > max {
+		return max + 1
+	}
+
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if cur[j] < rowMin {
+				rowMin = cur[j]
+			}
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev, cur = cur, prev
+	}
+	if prev[len(rb)] > max {
+		return max + 1
+	}
+	return prev[len(rb)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
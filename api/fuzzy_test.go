@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dxe/url-shortcuts-go/model"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		max  int
+		want int
+	}{
+		{"join", "join", 2, 0},
+		{"join", "joim", 2, 1},
+		{"join", "joins", 2, 1},
+		{"join", "jon", 2, 1},
+		{"join", "jion", 2, 2},
+		{"kitten", "sitting", 3, 3},
+		{"", "abc", 3, 3},
+		{"abc", "", 5, 3},
+		{"café", "cafe", 1, 1},
+		// Distances over max are reported as max+1, whether the lengths
+		// already differ by too much or no row of the table is within max.
+		{"kitten", "sitting", 2, 3},
+		{"a", "abcdef", 2, 3},
+		{"abcdef", "ghijkl", 1, 2},
+		{"volunteer", "donate", 3, 4},
+	}
+	for _, test := range tests {
+		if got := levenshtein(test.a, test.b, test.max); got != test.want {
+			t.Errorf("levenshtein(%q, %q, %v) = %v, want %v", test.a, test.b, test.max, got, test.want)
+		}
+	}
+}
+
+func TestLevenshteinStopsEarlyConsistently(t *testing.T) {
+	codes := []string{"", "a", "join", "jion", "joint", "joni", "volunteer", "volunteer-la", "voluntter", "donate", "events"}
+	for _, a := range codes {
+		for _, b := range codes {
+			full := levenshtein(a, b, 100)
+			if other := levenshtein(b, a, 100); other != full {
+				t.Errorf("levenshtein(%q, %q) = %v, but %v the other way around", a, b, full, other)
+			}
+			for max := 0; max <= 3; max++ {
+				want := full
+				if want > max {
+					want = max + 1
+				}
+				if got := levenshtein(a, b, max); got != want {
+					t.Errorf("levenshtein(%q, %q, %v) = %v, want %v", a, b, max, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestCloseMatch(t *testing.T) {
+	tests := []struct {
+		code    string
+		matches []codeMatch
+		want    string
+	}{
+		{"joinn", []codeMatch{{"join", 1}, {"joint", 2}}, "join"},
+		{"jion", []codeMatch{{"join", 2}, {"jio", 1}}, "jio"},
+		// Ambiguous matches and matches further away aren't close.
+		{"joinn", []codeMatch{{"join", 1}, {"joins", 1}}, ""},
+		{"jion", []codeMatch{{"join", 2}}, ""},
+		{"volunteer", []codeMatch{{"volunteer-la", 3}}, ""},
+		{"events", nil, ""},
+		// Codes shorter than four characters never match closely.
+		{"jon", []codeMatch{{"join", 1}}, ""},
+		{"ab", []codeMatch{{"abc", 1}}, ""},
+		{"éé", []codeMatch{{"ééé", 1}}, ""},
+	}
+	for _, test := range tests {
+		got, ok := closeMatch(test.code, test.matches)
+		if got != test.want || ok != (test.want != "") {
+			t.Errorf("closeMatch(%q, %v) = %q, %v, want %q", test.code, test.matches, got, ok, test.want)
+		}
+	}
+}
+
+func TestCodeIndexMatch(t *testing.T) {
+	store := model.NewMemoryStore()
+	past := model.NullTime{Time: time.Now().Add(-time.Hour), Valid: true}
+	for _, settings := range []model.ShortcutSettings{
+		{Code: "join", Visibility: model.VisibilityPublic},
+		{Code: "joint", Visibility: model.VisibilityPublic},
+		{Code: "volunteer", Visibility: model.VisibilityPublic},
+		{Code: "volunteer-la", Visibility: model.VisibilityPublic},
+		{Code: "donate", Visibility: model.VisibilityPublic},
+		{Code: "joins", Visibility: model.VisibilityPassword},
+		{Code: "jain", Visibility: model.VisibilityPublic, ExpiresAt: past},
+	} {
+		settings.URL = "https://example.com/" + settings.Code
+		if _, err := store.InsertShortcut(model.Shortcut{ShortcutSettings: settings}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ix := newCodeIndex(store, time.Minute)
+
+	tests := []struct {
+		code string
+		n    int
+		want string
+	}{
+		// Protected and expired codes aren't suggested.
+		{"jain", 10, "[{join 1}]"},
+		// Short codes only allow a single edit, and swapping two characters
+		// takes two.
+		{"jion", 10, "[]"},
+		{"joinn", 10, "[{join 1} {joint 1}]"},
+		{"joinn", 1, "[{join 1}]"},
+		{"voluntteer", 10, "[{volunteer 1}]"},
+		// Prefixes match however far apart they are.
+		{"vol", 10, "[{volunteer 2} {volunteer-la 2}]"},
+		{"volunteer", 10, "[{volunteer 0} {volunteer-la 3}]"},
+		{"donat", 10, "[{donate 1}]"},
+		{"events", 10, "[]"},
+	}
+	for _, test := range tests {
+		matches, err := ix.Match(test.code, test.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint(matches); got != test.want {
+			t.Errorf("Match(%q, %v) = %v, want %v", test.code, test.n, got, test.want)
+		}
+	}
+}
@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/json"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"golang.org/x/oauth2"
@@ -19,18 +25,58 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/go-chi/jwtauth/v5"
-	"github.com/jmoiron/sqlx"
-	"github.com/patrickmn/go-cache"
 )
 
 type server struct {
 	prod              bool
 	port              int
-	db                *sqlx.DB
+	baseURL           string
+	store             model.Store
 	googleOauthConfig *oauth2.Config
 	tokenAuth         *jwtauth.JWTAuth
 	requestGroup      singleflight.Group
-	cache             *cache.Cache
+	redirectCache     RedirectCache
+	// cacheMu orders filling redirectCache after a lookup against
+	// invalidating it after a mutation. cacheGeneration counts the
+	// invalidations, so that a lookup that read a shortcut before it was
+	// changed doesn't cache the old version.
+	cacheMu         sync.Mutex
+	cacheGeneration uint64
+	visits          *visitRecorder
+	visitorHasher   visitorHasher
+	codes           codeGenerator
+	misses          *missRecorder
+	codeIndex       *codeIndex
+	utm             utmDefaults
+	// unlockKey signs the cookies that remember that a visitor entered a
+	// shortcut's password.
+	unlockKey []byte
+	// unlockLimiter limits how many wrong passwords can be entered for
+	// protected shortcuts.
+	unlockLimiter *unlockLimiter
+	// geoIP finds visitors' countries for routing rules. If nil, country
+	// rules never match.
+	geoIP *geoIP
+	// fuzzyMatching enables redirecting mistyped codes to the only similar
+	// code, and suggesting similar codes before falling back.
+	fuzzyMatching bool
+	// fallbackMode is what happens when a code doesn't exist, see
+	// handleMissing. In redirect mode, the code as requested is appended
+	// to fallbackURL.
+	fallbackMode string
+	fallbackURL  *url.URL
+	// expiredURL is where expired shortcuts redirect to. If empty, they
+	// respond with 410 Gone instead.
+	expiredURL string
+	// permanentRedirectMaxAge is how long browsers may cache redirects of
+	// shortcuts that use a permanent redirect status.
+	permanentRedirectMaxAge time.Duration
+	// interstitialDelay is how long interstitial pages count down before
+	// sending visitors on.
+	interstitialDelay time.Duration
+	// qrLogo can be placed in the middle of QR codes. If nil, QR codes have
+	// no logo.
+	qrLogo *qrLogo
 }
 
 func mustGetEnv(key string) string {
@@ -55,24 +101,241 @@ func mustGetEnvBool(key string) bool {
 	return strings.ToLower(v) == "true" || v == "1"
 }
 
+func getEnv(key, fallback string) string {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	return v
+}
+
+func getEnvInt(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	x, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatalf("expected env value for key %v to be int", key)
+	}
+	return x
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("expected env value for key %v to be a duration", key)
+	}
+	return d
+}
+
+// getEnvPositiveDuration is like getEnvDuration, but also rejects durations
+// that aren't positive, such as intervals of background jobs.
+func getEnvPositiveDuration(key string, fallback time.Duration) time.Duration {
+	d := getEnvDuration(key, fallback)
+	if d <= 0 {
+		log.Fatalf("expected env value for key %v to be a positive duration", key)
+	}
+	return d
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	return strings.ToLower(v) == "true" || v == "1"
+}
+
+// visitorHashSecret returns the key for hashing visitor IP addresses. If
+// VISITOR_HASH_SECRET isn't set, a key is derived from JWT_SECRET.
+func visitorHashSecret() []byte {
+	if secret := os.Getenv("VISITOR_HASH_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	mac := hmac.New(sha256.New, []byte(mustGetEnv("JWT_SECRET")))
+	mac.Write([]byte("visitor-hash"))
+	return mac.Sum(nil)
+}
+
+// unlockKey returns the key for signing unlock cookies, derived from
+// JWT_SECRET.
+func unlockKey() []byte {
+	mac := hmac.New(sha256.New, []byte(mustGetEnv("JWT_SECRET")))
+	mac.Write([]byte("shortcut-unlock"))
+	return mac.Sum(nil)
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			runMigrate(os.Args[2:])
+			return
+		case "normalize-codes":
+			runNormalizeCodes(os.Args[2:])
+			return
+		}
+	}
+
+	baseURL := mustGetEnv("BASE_URL")
 	googleOauthConfig := &oauth2.Config{
-		RedirectURL:  mustGetEnv("BASE_URL") + "/auth/callback",
+		RedirectURL:  baseURL + "/auth/callback",
 		ClientID:     mustGetEnv("OAUTH_CLIENT_ID"),
 		ClientSecret: mustGetEnv("OAUTH_CLIENT_SECRET"),
 		Scopes:       []string{"email", "profile"},
 		Endpoint:     google.Endpoint,
 	}
 
+	store, err := model.OpenStore(mustGetEnv("DB_DSN"))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	migrateOnStart(store)
+
+	codes, err := newCodeGenerator(getEnv("CODE_ALPHABET", defaultCodeAlphabet), getEnvInt("CODE_LENGTH", 6))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	fallbackMode := getEnv("FALLBACK_MODE", fallbackRedirect)
+	if err := validateFallbackMode(fallbackMode); err != nil {
+		log.Fatalln(err)
+	}
+	fallbackURL, err := parseFallbackURL(getEnv("FALLBACK_URL", "http://directactioneverywhere.com/"))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	visits, err := newVisitRecorder(store, visitRecorderConfig{
+		QueueSize:      getEnvInt("VISIT_QUEUE_SIZE", 10000),
+		Workers:        getEnvInt("VISIT_WORKERS", 2),
+		BatchSize:      getEnvInt("VISIT_BATCH_SIZE", 100),
+		FlushInterval:  getEnvDuration("VISIT_FLUSH_INTERVAL", time.Second),
+		EnqueueTimeout: getEnvDuration("VISIT_ENQUEUE_TIMEOUT", 0),
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var geo *geoIP
+	if path := getEnv("GEOIP_DB_PATH", ""); path != "" {
+		if geo, err = openGeoIP(path); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	var logo *qrLogo
+	if path := getEnv("QR_LOGO_PATH", ""); path != "" {
+		if logo, err = loadQRLogo(path); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
 	s := server{
 		prod:              mustGetEnvBool("PROD"),
 		port:              mustGetEnvInt("PORT"),
-		db:                model.InitDBConn(mustGetEnv("DB_DSN")),
+		baseURL:           baseURL,
+		store:             store,
 		googleOauthConfig: googleOauthConfig,
 		tokenAuth:         jwtauth.New("HS256", []byte(mustGetEnv("JWT_SECRET")), nil),
-		cache:             cache.New(5*time.Second, 5*time.Minute),
+		redirectCache: newMemoryRedirectCache(
+			getEnvDuration("REDIRECT_CACHE_TTL", 5*time.Second),
+			getEnvDuration("REDIRECT_CACHE_MISSING_TTL", 10*time.Second),
+		),
+		visits:        visits,
+		visitorHasher: visitorHasher{secret: visitorHashSecret()},
+		unlockKey:     unlockKey(),
+		unlockLimiter: newUnlockLimiter(),
+		codes:         codes,
+		misses:        newMissRecorder(store, 10000),
+		codeIndex:     newCodeIndex(store, time.Minute),
+		fuzzyMatching: getEnvBool("FUZZY_MATCHING", true),
+		geoIP:         geo,
+		utm: utmDefaults{
+			Source:         getEnv("UTM_SOURCE", "dxe-io"),
+			Medium:         getEnv("UTM_MEDIUM", "shortlink"),
+			CampaignPrefix: getEnv("UTM_CAMPAIGN_PREFIX", "dxe-io-"),
+		},
+		fallbackMode: fallbackMode,
+		fallbackURL:  fallbackURL,
+		expiredURL:   getEnv("EXPIRED_SHORTCUT_URL", ""),
+
+		permanentRedirectMaxAge: getEnvDuration("PERMANENT_REDIRECT_MAX_AGE", 24*time.Hour),
+		interstitialDelay:       getEnvDuration("INTERSTITIAL_DELAY", 5*time.Second),
+		qrLogo:                  logo,
+	}
+
+	// Background jobs and the server stop on SIGINT or SIGTERM.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	sweepInterval := getEnvPositiveDuration("EXPIRATION_SWEEP_INTERVAL", time.Minute)
+	missesInterval := getEnvPositiveDuration("MISSED_CODES_FLUSH_INTERVAL", 10*time.Second)
+	rollupInterval := getEnvPositiveDuration("ROLLUP_INTERVAL", time.Hour)
+	retentionDays := getEnvInt("VISIT_RETENTION_DAYS", 0)
+
+	// Jobs are waited for before the database is closed, so that a rollup
+	// or sweep that is still running can finish.
+	var jobs sync.WaitGroup
+	runJob := func(job func()) {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			job()
+		}()
+	}
+	runJob(func() { s.sweepExpiredShortcuts(ctx, sweepInterval) })
+	runJob(func() { s.misses.Run(ctx, missesInterval) })
+	runJob(func() { s.rollupVisits(ctx, rollupInterval, retentionDays) })
+
+	srv := &http.Server{
+		Addr:              ":" + strconv.Itoa(s.port),
+		Handler:           s.routes(),
+		ReadHeaderTimeout: getEnvDuration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+		ReadTimeout:       getEnvDuration("HTTP_READ_TIMEOUT", 15*time.Second),
+		// Longer than the 30 second request timeout middleware, so that timed
+		// out requests still get a response.
+		WriteTimeout: getEnvDuration("HTTP_WRITE_TIMEOUT", 35*time.Second),
+		IdleTimeout:  getEnvDuration("HTTP_IDLE_TIMEOUT", 2*time.Minute),
+	}
+
+	go func() {
+		log.Printf("Server started. Listening on %v.", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalln(err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Println("Shutting down. Waiting for in-flight requests to finish.")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second))
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down server gracefully: %v", err)
 	}
 
+	// Requests and jobs are done, so no more visits or misses will be
+	// recorded.
+	jobs.Wait()
+	s.visits.Close()
+	s.misses.Flush()
+	s.geoIP.Close()
+
+	if err := store.Close(); err != nil {
+		log.Printf("Failed to close database: %v", err)
+	}
+	log.Println("Server stopped.")
+}
+
+// routes returns the handler for every route the server serves.
+func (s *server) routes() http.Handler {
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
@@ -103,10 +366,9 @@ func main() {
 
 	// Redirect to whatever the short link points to
 	r.Get("/*", s.handleRedirect)
+	r.Post("/*", s.handleUnlock)
 
-	addr := ":" + strconv.Itoa(s.port)
-	log.Printf("Server started. Listening on %v.", addr)
-	log.Fatalln(http.ListenAndServe(addr, r))
+	return r
 }
 
 func (s *server) apiRouter(r chi.Router) {
@@ -116,13 +378,22 @@ func (s *server) apiRouter(r chi.Router) {
 	r.Use(userAuthorizer)
 
 	r.Get("/me", s.getCurrentUser)
+	r.Get("/visits/queue", s.getVisitRecorderStats)
 
 	r.Route("/shortcuts", func(r chi.Router) {
 		r.Get("/", s.getShortcuts)
 		r.Post("/", s.createShortcut)
 		r.Put("/{id}", s.updateShortcut)
 		r.Delete("/{id}", s.deleteShortcut)
+		r.Get("/available", s.getCodeAvailability)
+		r.Get("/missed", s.getMissedCodes)
 		r.Get("/top", s.getTopShortcuts)
+		r.Get("/deleted", s.getDeletedShortcuts)
+		r.Get("/{id}/history", s.getShortcutHistory)
+		r.Get("/{id}/stats", s.getShortcutStats)
+		r.Get("/{id}/variants", s.getVariantStats)
+		r.Get("/{id}/qr", s.getShortcutQR)
+		r.Post("/{id}/revert/{rev}", s.revertShortcut)
 	})
 
 	r.Route("/users", func(r chi.Router) {
@@ -139,74 +410,165 @@ func (s *server) handleHealthcheck(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *server) handleRedirect(w http.ResponseWriter, r *http.Request) {
-	code := r.URL.Path[1:]
+	path := r.URL.Path[1:]
+	preview := strings.HasSuffix(path, previewSuffix)
+	path = strings.TrimSuffix(path, previewSuffix)
+
+	code := model.NormalizeCode(path)
 	log.Printf("Code from request: %v\n", code)
 
-	v, found := s.cache.Get(code)
-	if !found {
-		var err error
-		v, err, _ = s.requestGroup.Do(code, func() (interface{}, error) {
-			return model.GetShortcutByCode(s.db, code)
-		})
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		s.cache.Set(code, v, cache.DefaultExpiration)
+	shortcut, rest, err := s.resolveShortcut(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch shortcut.StateAt(time.Now()) {
+	case model.ShortcutScheduled:
+		// Not live yet, so behave as if it doesn't exist.
+		shortcut = model.Shortcut{}
+	case model.ShortcutExpired:
+		s.handleExpired(w, r)
+		return
 	}
 
-	shortcut := v.(model.Shortcut)
 	if shortcut.ID == 0 {
-		path := "http://directactioneverywhere.com/" + code // TODO: move domain to env
-		http.Redirect(w, r, path, http.StatusFound)
+		s.handleMissing(w, r, path)
+		return
+	}
+
+	if !s.authorizeVisit(w, r, shortcut) {
+		return
+	}
+
+	if preview {
+		s.handlePreview(w, r, shortcut, path, rest)
+		return
+	}
+
+	query := r.URL.Query()
+	source := visitSource(query)
+	if shortcut.Interstitial && !continuedFromInterstitial(query) {
+		s.renderInterstitial(w, r, shortcut, path, rest)
 		return
 	}
 
-	path, err := url.Parse(shortcut.URL)
+	// The URL of the matching routing rule, if any, replaces the shortcut's.
+	// Visitors who match no rule are split between the variants.
+	var branch, variant string
+	shortcut.URL, branch = s.chooseRoute(r, shortcut)
+	if variantsApply(shortcut, branch) {
+		v, err := chooseVariant(w, r, shortcut)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		shortcut.URL, variant = v.URL, v.Name
+	}
+
+	target, used, err := targetURL(shortcut, rest, query)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	// Query parameters that were filled into the URL aren't passed on again.
+	for _, param := range used {
+		query.Del(param)
+	}
 
-	path.RawQuery = buildQueryString(r, code, path.Query(), r.URL.Query())
+	target.RawQuery = s.utm.buildQueryString(r, shortcut, target.Query(), query)
 
-	http.Redirect(w, r, path.String(), http.StatusFound)
+	setRedirectCacheControl(w, shortcut, s.permanentRedirectMaxAge, time.Now())
+	http.Redirect(w, r, target.String(), redirectStatus(shortcut))
 
-	go func() {
-		if err := model.InsertVisit(s.db, model.Visit{
-			ShortcutID: shortcut.ID,
-			IPAddress:  r.RemoteAddr,
-			Path:       r.URL.String(),
-			Referer:    r.Header.Get("Referer"),
-			UserAgent:  r.Header.Get("User-Agent"),
-		}); err != nil {
-			log.Println(err)
+	s.visits.Record(model.Visit{
+		ShortcutID:  shortcut.ID,
+		VisitorHash: s.visitorHasher.Hash(r.RemoteAddr, time.Now()),
+		Path:        r.URL.String(),
+		Referer:     r.Header.Get("Referer"),
+		UserAgent:   r.Header.Get("User-Agent"),
+		Branch:      branch,
+		Variant:     variant,
+		Source:      source,
+	})
+}
+
+// resolveShortcut finds the shortcut for a request path. It tries the
+// normalized code, then the code exactly as requested, since codes saved before
+// normalization may not have been normalized if they collide with another code,
+// and finally the longest matching prefix shortcut. For prefix shortcuts, it
+// also returns the rest of the path after the code.
+func (s *server) resolveShortcut(path string) (model.Shortcut, string, error) {
+	code := model.NormalizeCode(path)
+	shortcut, err := s.lookupShortcut(code)
+	if err != nil || shortcut.ID != 0 {
+		return shortcut, "", err
+	}
+
+	if path != code {
+		shortcut, err = s.lookupShortcut(path)
+		if err != nil || shortcut.ID != 0 {
+			return shortcut, "", err
 		}
-	}()
+	}
+
+	for i := strings.LastIndex(path, "/"); i > 0; i = strings.LastIndex(path[:i], "/") {
+		shortcut, err := s.lookupShortcut(model.NormalizeCode(path[:i]))
+		if err != nil {
+			return model.Shortcut{}, "", err
+		}
+		if shortcut.ID != 0 && shortcut.IsPrefix {
+			return shortcut, path[i+1:], nil
+		}
+	}
 
+	return model.Shortcut{}, "", nil
 }
 
-func buildQueryString(r *http.Request, campaign string, args ...url.Values) string {
-	output := make(url.Values, 0)
-	// Merge URL query parameters, overwriting values from earlier args with later args.
-	for _, u := range args {
-		for k, v := range u {
-			output.Set(k, v[0])
-		}
+// lookupShortcut returns the shortcut for a code, or a zero-value Shortcut if
+// there is none. Lookups are cached, and concurrent lookups for the same code
+// share a single database query.
+func (s *server) lookupShortcut(code string) (model.Shortcut, error) {
+	if shortcut, found := s.redirectCache.Get(code); found {
+		return shortcut, nil
 	}
-	if output.Get("utm_source") == "" {
-		switch referer := r.Header.Get("Referer"); referer {
-		case "":
-			output.Set("utm_source", "dxe-io")
-		default:
-			output.Set("utm_source", referer)
+
+	v, err, _ := s.requestGroup.Do(code, func() (interface{}, error) {
+		s.cacheMu.Lock()
+		generation := s.cacheGeneration
+		s.cacheMu.Unlock()
+
+		shortcut, err := s.store.GetShortcutByCode(code)
+		if err != nil {
+			return nil, err
 		}
+
+		s.cacheMu.Lock()
+		defer s.cacheMu.Unlock()
+		if s.cacheGeneration == generation {
+			s.redirectCache.Set(code, shortcut)
+		}
+		return shortcut, nil
+	})
+	if err != nil {
+		return model.Shortcut{}, err
 	}
-	if output.Get("utm_medium") == "" {
-		output.Set("utm_medium", "shortlink")
+
+	return v.(model.Shortcut), nil
+}
+
+// invalidateShortcuts removes codes from the redirect cache after a mutation.
+// Lookups that are already running may have read the old shortcuts, so they
+// aren't cached or shared with later lookups.
+func (s *server) invalidateShortcuts(codes ...string) {
+	s.cacheMu.Lock()
+	defer s.cacheMu.Unlock()
+
+	s.cacheGeneration++
+	for _, code := range codes {
+		s.requestGroup.Forget(code)
 	}
-	output.Set("utm_campaign", "dxe-io-"+campaign)
-	return output.Encode()
+	s.redirectCache.Invalidate(codes...)
 }
 
 func writeJSON(w http.ResponseWriter, data interface{}) {
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dxe/url-shortcuts-go/model"
+	"github.com/go-chi/jwtauth/v5"
+)
+
+// testStores returns an empty memory store and an empty, migrated SQLite
+// store, so that handlers can be tested against both.
+func testStores(t *testing.T) map[string]model.Store {
+	t.Helper()
+	sqlite, err := model.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlite.Close() })
+	if err := sqlite.MigrateUp(); err != nil {
+		t.Fatal(err)
+	}
+	return map[string]model.Store{
+		"memory": model.NewMemoryStore(),
+		"sqlite": sqlite,
+	}
+}
+
+// newTestServer returns a server configured like in production, minus
+// anything that needs the network.
+func newTestServer(t *testing.T, store model.Store) *server {
+	t.Helper()
+	visits, err := newVisitRecorder(store, visitRecorderConfig{
+		QueueSize:     100,
+		Workers:       1,
+		BatchSize:     10,
+		FlushInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(visits.Close)
+
+	codes, err := newCodeGenerator(defaultCodeAlphabet, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fallbackURL, err := parseFallbackURL("https://example.org/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &server{
+		baseURL:       "https://dxe.io",
+		store:         store,
+		tokenAuth:     jwtauth.New("HS256", []byte("test-secret"), nil),
+		redirectCache: newMemoryRedirectCache(time.Minute, time.Minute),
+		visits:        visits,
+		visitorHasher: visitorHasher{secret: []byte("test-secret")},
+		unlockKey:     []byte("test-secret"),
+		unlockLimiter: newUnlockLimiter(),
+		codes:         codes,
+		misses:        newMissRecorder(store, 100),
+		codeIndex:     newCodeIndex(store, time.Minute),
+		utm: utmDefaults{
+			Source:         "dxe-io",
+			Medium:         "shortlink",
+			CampaignPrefix: "dxe-io-",
+		},
+		fallbackMode: fallbackRedirect,
+		fallbackURL:  fallbackURL,
+
+		permanentRedirectMaxAge: time.Hour,
+		interstitialDelay:       time.Second,
+	}
+}
+
+// loginCookie creates an active admin and returns the cookie that logs them
+// in, as set by issueJWTToken.
+func loginCookie(t *testing.T, s *server) *http.Cookie {
+	t.Helper()
+	user := model.User{Name: "Test", Email: "test@directactioneverywhere.com", Active: true, Admin: true}
+	id, err := s.store.InsertUser(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.ID = int(id)
+
+	claims := map[string]interface{}{"user": user}
+	jwtauth.SetExpiryIn(claims, time.Hour)
+	_, token, err := s.tokenAuth.Encode(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Cookie{Name: cookieJWT, Value: token}
+}
+
+func serve(s *server, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.routes().ServeHTTP(w, r)
+	return w
+}
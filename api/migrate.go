@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/dxe/url-shortcuts-go/model"
+)
+
+const migrateUsage = "usage: url-shortcuts migrate [up | down [steps] | status]"
+
+// runMigrate implements the migrate subcommand. Only DB_DSN needs to be set.
+func runMigrate(args []string) {
+	store, err := model.OpenStore(mustGetEnv("DB_DSN"))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer store.Close()
+
+	migrator, ok := store.(model.Migrator)
+	if !ok {
+		log.Println("This store does not use migrations.")
+		return
+	}
+
+	cmd := "up"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+
+	switch cmd {
+	case "up":
+		err = migrator.MigrateUp()
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				log.Fatalln(migrateUsage)
+			}
+		}
+		err = migrator.MigrateDown(steps)
+	case "status":
+		var status []model.MigrationStatus
+		status, err = migrator.MigrationStatus()
+		for _, m := range status {
+			state := "pending"
+			if m.Applied {
+				state = "applied " + m.AppliedAt
+			}
+			fmt.Fprintf(os.Stdout, "%04d_%v\t%v\n", m.Version, m.Name, state)
+		}
+	default:
+		log.Fatalln(migrateUsage)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// migrateOnStart applies pending migrations unless DB_AUTO_MIGRATE is false.
+func migrateOnStart(store model.Store) {
+	if !getEnvBool("DB_AUTO_MIGRATE", true) {
+		return
+	}
+	migrator, ok := store.(model.Migrator)
+	if !ok {
+		return
+	}
+	if err := migrator.MigrateUp(); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+}
+
+// runNormalizeCodes implements the normalize-codes subcommand, which normalizes
+// the codes of shortcuts created before codes were normalized. Only DB_DSN
+// needs to be set.
+func runNormalizeCodes(args []string) {
+	flags := flag.NewFlagSet("normalize-codes", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "only print the changes that would be made")
+	flags.Parse(args)
+
+	store, err := model.OpenStore(mustGetEnv("DB_DSN"))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer store.Close()
+
+	changes, err := model.NormalizeCodes(store, *dryRun)
+	for _, c := range changes {
+		switch {
+		case len(c.CollidesWith) > 0:
+			fmt.Fprintf(os.Stdout, "%v\t%q -> %q\tskipped, collides with shortcuts %v\n", c.ShortcutID, c.From, c.To, c.CollidesWith)
+		case *dryRun:
+			fmt.Fprintf(os.Stdout, "%v\t%q -> %q\n", c.ShortcutID, c.From, c.To)
+		default:
+			fmt.Fprintf(os.Stdout, "%v\t%q -> %q\tupdated\n", c.ShortcutID, c.From, c.To)
+		}
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
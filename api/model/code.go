@@ -0,0 +1,79 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeCode returns the canonical form of a shortcut code, so that codes
+// typed by hand still match: surrounding whitespace and trailing slashes are
+// removed, compatibility characters such as full-width letters are replaced by
+// their plain equivalents, and case is folded.
+func NormalizeCode(code string) string {
+	code = norm.NFKC.String(code)
+	code = strings.TrimSpace(code)
+	code = strings.TrimRight(code, "/")
+	code = strings.TrimSpace(code)
+	return cases.Fold().String(code)
+}
+
+// CodeNormalization is the result of normalizing an existing shortcut's code.
+type CodeNormalization struct {
+	ShortcutID int
+	From       string
+	To         string
+	// CollidesWith lists the IDs of other shortcuts whose codes normalize to
+	// the same value. Colliding codes are left as they are.
+	CollidesWith []int
+}
+
+// NormalizeCodes normalizes the codes of existing shortcuts, which were saved
+// before codes were normalized. Codes that would collide with another
+// shortcut's code once normalized are skipped and have to be resolved by hand;
+// until then, they are still found by exact match. If dryRun is set, nothing
+// is changed.
+func NormalizeCodes(store Store, dryRun bool) ([]CodeNormalization, error) {
+	shortcuts, _, err := store.ListShortcuts(ListShortcutOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	byCode := make(map[string][]Shortcut)
+	for _, s := range shortcuts {
+		code := NormalizeCode(s.Code)
+		byCode[code] = append(byCode[code], s)
+	}
+
+	var changes []CodeNormalization
+	for code, group := range byCode {
+		for _, s := range group {
+			if s.Code == code {
+				continue
+			}
+			change := CodeNormalization{ShortcutID: s.ID, From: s.Code, To: code}
+			for _, other := range group {
+				if other.ID != s.ID {
+					change.CollidesWith = append(change.CollidesWith, other.ID)
+				}
+			}
+			changes = append(changes, change)
+
+			if dryRun || len(change.CollidesWith) > 0 {
+				continue
+			}
+			s.Code = code
+			if err := store.UpdateShortcut(s); err != nil {
+				return changes, fmt.Errorf("failed to normalize code %q: %w", change.From, err)
+			}
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].ShortcutID < changes[j].ShortcutID
+	})
+	return changes, nil
+}
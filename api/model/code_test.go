@@ -0,0 +1,70 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"join", "join"},
+		{"Join", "join"},
+		{"  join/ ", "join"},
+		{"join//", "join"},
+		{"Docs/Meetings/", "docs/meetings"},
+		{"ＪＯＩＮ", "join"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeCode(tt.code); got != tt.want {
+			t.Errorf("NormalizeCode(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeCodes(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ids := make(map[string]int)
+			for _, code := range []string{"join", "Join", "Volunteer"} {
+				id, err := store.InsertShortcut(Shortcut{ShortcutSettings: ShortcutSettings{Code: code, URL: "https://example.com/" + code, UTMMode: UTMInherit}})
+				if err != nil {
+					t.Fatal(err)
+				}
+				ids[code] = int(id)
+			}
+
+			dryRun, err := NormalizeCodes(store, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []CodeNormalization{
+				{ShortcutID: ids["Join"], From: "Join", To: "join", CollidesWith: []int{ids["join"]}},
+				{ShortcutID: ids["Volunteer"], From: "Volunteer", To: "volunteer"},
+			}
+			if !reflect.DeepEqual(dryRun, want) {
+				t.Fatalf("dry run = %+v, want %+v", dryRun, want)
+			}
+			if s, _ := store.GetShortcutByCode("volunteer"); s.ID != 0 {
+				t.Fatal("dry run changed a code")
+			}
+
+			changes, err := NormalizeCodes(store, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(changes, want) {
+				t.Fatalf("changes = %+v, want %+v", changes, want)
+			}
+			if s, _ := store.GetShortcutByCode("volunteer"); s.ID != ids["Volunteer"] {
+				t.Error("code wasn't normalized")
+			}
+			if s, _ := store.GetShortcutByCode("Join"); s.ID != ids["Join"] {
+				t.Error("colliding code was changed")
+			}
+		})
+	}
+}
@@ -1,27 +1,75 @@
 package model
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
 )
 
-func InitDBConn(dsn string) *sqlx.DB {
+type dialect int
+
+const (
+	dialectMySQL dialect = iota
+	dialectSQLite
+)
+
+// SQLStore implements Store on top of a MySQL or SQLite database.
+type SQLStore struct {
+	db      *sqlx.DB
+	dialect dialect
+}
+
+func NewMySQLStore(dsn string) (*SQLStore, error) {
 	db, err := sqlx.Open("mysql", dsn)
 	if err != nil {
-		log.Fatalf("Failed to open database: %v", err.Error())
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
 	db.SetConnMaxLifetime(4 * time.Hour)
 	db.SetConnMaxIdleTime(15 * time.Minute)
 
 	if err := db.Ping(); err != nil {
-		log.Fatalf("Failed to establish connection to database: %v", err.Error())
+		return nil, fmt.Errorf("failed to establish connection to database: %w", err)
+	}
+
+	log.Println("Connected to MySQL database")
+
+	return &SQLStore{db: db, dialect: dialectMySQL}, nil
+}
+
+func NewSQLiteStore(path string) (*SQLStore, error) {
+	db, err := sqlx.Open("sqlite3", "file:"+path+"?_busy_timeout=5000&_foreign_keys=on")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	log.Println("Connected to database")
+	// SQLite only allows a single writer, and each connection to :memory: would
+	// otherwise get its own empty database.
+	db.SetMaxOpenConns(1)
+
+	log.Printf("Opened SQLite database %v", path)
+
+	return &SQLStore{db: db, dialect: dialectSQLite}, nil
+}
 
-	return db
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// isUniqueViolation reports whether err was caused by a unique constraint.
+func isUniqueViolation(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1062 // ER_DUP_ENTRY
+	}
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+	}
+	return false
 }
@@ -0,0 +1,590 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore implements Store without a database. Nothing is persisted, which
+// makes it useful for tests and for trying out the service locally.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	shortcuts map[int]Shortcut
+	users     map[int]User
+	visits    []Visit
+	revisions []ShortcutRevision
+	lastIDs   map[string]int
+
+	dailyCounts     map[string]DailyCount
+	rolledUpThrough NullTime
+	missedCodes     map[string]MissedCode
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		shortcuts: make(map[int]Shortcut),
+		users:     make(map[int]User),
+		lastIDs:   make(map[string]int),
+
+		dailyCounts: make(map[string]DailyCount),
+		missedCodes: make(map[string]MissedCode),
+	}
+}
+
+// nextID emulates an auto-increment column for the given table.
+func (m *MemoryStore) nextID(table string) int {
+	m.lastIDs[table]++
+	return m.lastIDs[table]
+}
+
+func (m *MemoryStore) GetShortcutByCode(code string) (Shortcut, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, s := range m.shortcuts {
+		if s.Code == code {
+			return s, nil
+		}
+	}
+	return Shortcut{}, nil
+}
+
+func (m *MemoryStore) GetShortcutByID(id int) (Shortcut, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.shortcuts[id], nil
+}
+
+func (m *MemoryStore) ListShortcuts(opts ListShortcutOptions) ([]Shortcut, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	shortcuts := make([]Shortcut, 0)
+	for _, s := range m.shortcuts {
+		if opts.Code != "" && !strings.HasPrefix(s.Code, opts.Code) {
+			continue
+		}
+		s.UpdatedByName = m.users[s.UpdatedBy].Name
+		shortcuts = append(shortcuts, s)
+	}
+	sort.Slice(shortcuts, func(i, j int) bool {
+		if shortcuts[i].UpdatedAt != shortcuts[j].UpdatedAt {
+			return shortcuts[i].UpdatedAt > shortcuts[j].UpdatedAt
+		}
+		return shortcuts[i].ID > shortcuts[j].ID
+	})
+	total := len(shortcuts)
+
+	if opts.Limit > 0 {
+		start := (opts.Page - 1) * opts.Limit
+		if start < 0 {
+			start = 0
+		}
+		if start > total {
+			start = total
+		}
+		end := start + opts.Limit
+		if end > total {
+			end = total
+		}
+		shortcuts = shortcuts[start:end]
+	}
+
+	return shortcuts, total, nil
+}
+
+func (m *MemoryStore) InsertShortcut(shortcut Shortcut) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range m.shortcuts {
+		if s.Code == shortcut.Code {
+			return 0, ErrCodeTaken
+		}
+	}
+
+	shortcut.ID = m.nextID("shortcuts")
+	shortcut.CreatedAt, shortcut.UpdatedAt = now(), now()
+	shortcut.UpdatedByName = ""
+	m.shortcuts[shortcut.ID] = shortcut
+	m.recordRevision(shortcut, RevisionCreated, shortcut.CreatedBy)
+
+	return int64(shortcut.ID), nil
+}
+
+func (m *MemoryStore) UpdateShortcut(shortcut Shortcut) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.shortcuts[shortcut.ID]
+	if !ok {
+		return nil
+	}
+	for _, s := range m.shortcuts {
+		if s.Code == shortcut.Code && s.ID != shortcut.ID {
+			return ErrCodeTaken
+		}
+	}
+
+	existing.ShortcutSettings = shortcut.ShortcutSettings
+	existing.Expired = shortcut.Expired
+	existing.UpdatedAt = now()
+	existing.UpdatedBy = shortcut.UpdatedBy
+	m.shortcuts[shortcut.ID] = existing
+	m.recordRevision(existing, RevisionUpdated, shortcut.UpdatedBy)
+
+	return nil
+}
+
+func (m *MemoryStore) DeleteShortcut(shortcut Shortcut) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.shortcuts[shortcut.ID]
+	if !ok {
+		return nil
+	}
+	m.recordRevision(existing, RevisionDeleted, shortcut.UpdatedBy)
+	delete(m.shortcuts, shortcut.ID)
+
+	return nil
+}
+
+// recordRevision must be called with the write lock held.
+func (m *MemoryStore) recordRevision(s Shortcut, action string, changedBy int) {
+	revision := 1
+	for _, r := range m.revisions {
+		if r.ShortcutID == s.ID && r.Revision >= revision {
+			revision = r.Revision + 1
+		}
+	}
+	m.revisions = append(m.revisions, ShortcutRevision{
+		ID:               m.nextID("shortcut_revisions"),
+		ShortcutID:       s.ID,
+		Revision:         revision,
+		Action:           action,
+		ShortcutSettings: s.ShortcutSettings,
+		ChangedAt:        now(),
+		ChangedBy:        changedBy,
+	})
+}
+
+func (m *MemoryStore) ListShortcutRevisions(shortcutID int) ([]ShortcutRevision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	revisions := make([]ShortcutRevision, 0)
+	for i := len(m.revisions) - 1; i >= 0; i-- {
+		if r := m.revisions[i]; r.ShortcutID == shortcutID {
+			r.ChangedByName = m.users[r.ChangedBy].Name
+			revisions = append(revisions, r)
+		}
+	}
+
+	return revisions, nil
+}
+
+func (m *MemoryStore) GetShortcutRevision(shortcutID, revision int) (ShortcutRevision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, r := range m.revisions {
+		if r.ShortcutID == shortcutID && r.Revision == revision {
+			r.ChangedByName = m.users[r.ChangedBy].Name
+			return r, nil
+		}
+	}
+
+	return ShortcutRevision{}, nil
+}
+
+func (m *MemoryStore) ListDeletedShortcuts() ([]ShortcutRevision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	latest := make(map[int]ShortcutRevision)
+	for _, r := range m.revisions {
+		latest[r.ShortcutID] = r
+	}
+
+	revisions := make([]ShortcutRevision, 0)
+	for i := len(m.revisions) - 1; i >= 0; i-- {
+		r := m.revisions[i]
+		if _, exists := m.shortcuts[r.ShortcutID]; exists || r.Action != RevisionDeleted || latest[r.ShortcutID].ID != r.ID {
+			continue
+		}
+		r.ChangedByName = m.users[r.ChangedBy].Name
+		revisions = append(revisions, r)
+	}
+
+	return revisions, nil
+}
+
+func (m *MemoryStore) RevertShortcut(rev ShortcutRevision, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range m.shortcuts {
+		if s.Code == rev.Code && s.ID != rev.ShortcutID {
+			return ErrCodeTaken
+		}
+	}
+
+	shortcut, exists := m.shortcuts[rev.ShortcutID]
+	action := RevisionReverted
+	if !exists {
+		action = RevisionRestored
+		shortcut = Shortcut{ID: rev.ShortcutID, CreatedAt: now(), CreatedBy: userID}
+	}
+	shortcut.ShortcutSettings = rev.ShortcutSettings
+	shortcut.Expired = shortcut.StateAt(time.Now()) == ShortcutExpired
+	shortcut.UpdatedAt = now()
+	shortcut.UpdatedBy = userID
+
+	m.shortcuts[shortcut.ID] = shortcut
+	m.recordRevision(shortcut, action, userID)
+
+	return nil
+}
+
+func (m *MemoryStore) MarkExpiredShortcuts(now time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int64
+	for id, s := range m.shortcuts {
+		if !s.Expired && s.ExpiresAt.Valid && !now.Before(s.ExpiresAt.Time) {
+			s.Expired = true
+			m.shortcuts[id] = s
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+func (m *MemoryStore) GetTopShortcuts(period string) ([]TopShortcut, error) {
+	if err := validatePeriod(period); err != nil {
+		return nil, err
+	}
+
+	from := topPeriodStart(period, time.Now())
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rawFrom := from
+	if m.rolledUpThrough.Valid && !m.rolledUpThrough.Time.Before(from) {
+		rawFrom = m.rolledUpThrough.Time.AddDate(0, 0, 1)
+	}
+
+	clicks := make(map[int]int64)
+	uniques := make(map[int]int64)
+	for _, dc := range m.dailyCounts {
+		if dc.Day >= from.Format(dayLayout) && dc.Day < rawFrom.Format(dayLayout) {
+			clicks[dc.ShortcutID] += dc.Clicks
+			uniques[dc.ShortcutID] += dc.UniqueVisitors
+		}
+	}
+
+	cutoff := FormatTime(rawFrom)
+	visitors := make(map[int]map[string]struct{})
+	for _, v := range m.visits {
+		if v.Timestamp < cutoff {
+			continue
+		}
+		clicks[v.ShortcutID]++
+		if v.VisitorHash != "" {
+			if visitors[v.ShortcutID] == nil {
+				visitors[v.ShortcutID] = make(map[string]struct{})
+			}
+			// Hashes change daily, so this counts unique visitors per day.
+			visitors[v.ShortcutID][v.VisitorHash] = struct{}{}
+		}
+	}
+	for id, hashes := range visitors {
+		uniques[id] += int64(len(hashes))
+	}
+
+	shortcuts := make([]TopShortcut, 0, len(clicks))
+	for id, total := range clicks {
+		shortcut, ok := m.shortcuts[id]
+		if !ok {
+			continue
+		}
+		shortcuts = append(shortcuts, TopShortcut{
+			ID:             id,
+			Code:           shortcut.Code,
+			TotalVisits:    total,
+			UniqueVisitors: uniques[id],
+		})
+	}
+	sort.Slice(shortcuts, func(i, j int) bool {
+		return shortcuts[i].TotalVisits > shortcuts[j].TotalVisits
+	})
+	if len(shortcuts) > 10 {
+		shortcuts = shortcuts[:10]
+	}
+
+	return shortcuts, nil
+}
+
+func (m *MemoryStore) FindUserByEmail(email string) (User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, u := range m.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return User{}, nil
+}
+
+func (m *MemoryStore) GetUserByID(id int) (User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, u := range m.users {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+	return User{}, nil
+}
+
+func (m *MemoryStore) ListUsers() ([]User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.users) == 0 {
+		return nil, nil
+	}
+
+	users := make([]User, 0, len(m.users))
+	for _, u := range m.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Name < users[j].Name
+	})
+
+	return users, nil
+}
+
+func (m *MemoryStore) InsertUser(user User) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if u.Email == user.Email {
+			return 0, fmt.Errorf("error inserting user: duplicate email %q", user.Email)
+		}
+	}
+
+	user.ID = m.nextID("users")
+	user.CreatedAt = now()
+	user.LastLoggedIn = "Never"
+	m.users[user.ID] = user
+
+	return int64(user.ID), nil
+}
+
+func (m *MemoryStore) UpdateUser(user User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.users[user.ID]
+	if !ok {
+		return nil
+	}
+
+	existing.Name = user.Name
+	existing.Email = user.Email
+	existing.Active = user.Active
+	existing.Admin = user.Admin
+	m.users[user.ID] = existing
+
+	return nil
+}
+
+func (m *MemoryStore) DeleteUser(user User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.users, user.ID)
+	return nil
+}
+
+func (m *MemoryStore) UpdateUserLastLoggedIn(user User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.users[user.ID]
+	if !ok {
+		return nil
+	}
+
+	existing.LastLoggedIn = now()
+	m.users[user.ID] = existing
+
+	return nil
+}
+
+func (m *MemoryStore) InsertVisits(visits []Visit) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, visit := range visits {
+		visit.ID = m.nextID("visits")
+		if visit.Timestamp == "" {
+			visit.Timestamp = now()
+		}
+		m.visits = append(m.visits, visit)
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) ListVisits(shortcutID int, from, to time.Time) ([]Visit, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	start, end := FormatTime(from), FormatTime(ceilSecond(to))
+	visits := make([]Visit, 0)
+	for _, v := range m.visits {
+		if (shortcutID == 0 || v.ShortcutID == shortcutID) && v.Timestamp >= start && v.Timestamp < end {
+			visits = append(visits, v)
+		}
+	}
+
+	return visits, nil
+}
+
+func (m *MemoryStore) FirstVisitTime() (NullTime, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var first NullTime
+	for _, v := range m.visits {
+		if t, err := ParseTime(v.Timestamp); err == nil && (!first.Valid || t.Before(first.Time)) {
+			first = NewNullTime(t)
+		}
+	}
+
+	return first, nil
+}
+
+func (m *MemoryStore) DeleteVisitsBefore(t time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cutoff := FormatTime(t)
+	kept := m.visits[:0]
+	for _, v := range m.visits {
+		if v.Timestamp >= cutoff {
+			kept = append(kept, v)
+		}
+	}
+	deleted := int64(len(m.visits) - len(kept))
+	m.visits = kept
+
+	return deleted, nil
+}
+
+func (m *MemoryStore) RolledUpThrough() (NullTime, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.rolledUpThrough, nil
+}
+
+func (m *MemoryStore) SaveDailyCounts(day time.Time, counts []DailyCount) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, dc := range counts {
+		m.dailyCounts[fmt.Sprintf("%v/%v", dc.ShortcutID, dc.Day)] = dc
+	}
+	if day = startOfDay(day); !m.rolledUpThrough.Valid || day.After(m.rolledUpThrough.Time) {
+		m.rolledUpThrough = NewNullTime(day)
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) ListDailyCounts(shortcutID int, from, to time.Time) ([]DailyCount, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	start, end := startOfDay(from).Format(dayLayout), to.Format(dayLayout)
+	if startOfDay(to).Equal(to) {
+		end = to.AddDate(0, 0, -1).Format(dayLayout)
+	}
+
+	counts := make([]DailyCount, 0)
+	for _, dc := range m.dailyCounts {
+		if (shortcutID == 0 || dc.ShortcutID == shortcutID) && dc.Day >= start && dc.Day <= end {
+			counts = append(counts, dc)
+		}
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		return counts[i].Day < counts[j].Day
+	})
+
+	return counts, nil
+}
+
+func (m *MemoryStore) RecordMissedCodes(counts map[string]int64, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ts := FormatTime(at)
+	for code, n := range counts {
+		missed, ok := m.missedCodes[code]
+		if !ok {
+			missed = MissedCode{Code: code, FirstSeen: ts}
+		}
+		missed.Count += n
+		missed.LastSeen = ts
+		m.missedCodes[code] = missed
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) ListMissedCodes(limit int) ([]MissedCode, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	exists := make(map[string]bool)
+	for _, s := range m.shortcuts {
+		exists[s.Code] = true
+	}
+
+	codes := make([]MissedCode, 0)
+	for _, missed := range m.missedCodes {
+		if !exists[missed.Code] {
+			codes = append(codes, missed)
+		}
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if codes[i].Count != codes[j].Count {
+			return codes[i].Count > codes[j].Count
+		}
+		return codes[i].LastSeen > codes[j].LastSeen
+	})
+	if len(codes) > limit {
+		codes = codes[:limit]
+	}
+
+	return codes, nil
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,325 @@
+package model
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migrationLockName is the MySQL advisory lock held while migrating, so that
+// replicas starting at the same time don't race each other.
+const (
+	migrationLockName    = "url_shortcuts_schema_migrations"
+	migrationLockTimeout = 60 // seconds
+)
+
+// Migrator is implemented by stores with a schema that needs migrating.
+type Migrator interface {
+	MigrateUp() error
+	MigrateDown(steps int) error
+	MigrationStatus() ([]MigrationStatus, error)
+}
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt string
+}
+
+// loadMigrations reads the embedded migrations for a dialect, sorted by version.
+// Files are named <version>_<name>.up.sql and <version>_<name>.down.sql.
+//
+// MySQL commits DDL statements implicitly, so a MySQL migration that failed
+// part way couldn't be rolled back, and would be left half applied. Each MySQL
+// migration is therefore a single statement. SQLite migrations run in a
+// transaction, so they can have several.
+func loadMigrations(d dialect) ([]Migration, error) {
+	dir := "migrations/mysql"
+	if d == dialectSQLite {
+		dir = "migrations/sqlite"
+	}
+
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		var direction string
+		name := e.Name()
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction, name = "up", strings.TrimSuffix(name, ".up.sql")
+		case strings.HasSuffix(name, ".down.sql"):
+			direction, name = "down", strings.TrimSuffix(name, ".down.sql")
+		default:
+			continue
+		}
+
+		parts := strings.SplitN(name, "_", 2)
+		version, err := strconv.Atoi(parts[0])
+		if err != nil || len(parts) != 2 {
+			return nil, fmt.Errorf("invalid migration file name %v", e.Name())
+		}
+
+		b, err := migrationFiles.ReadFile(path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %v: %w", e.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.Up = string(b)
+		} else {
+			m.Down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %v has no up script", m.Version)
+		}
+		if d == dialectMySQL && (len(splitStatements(m.Up)) > 1 || len(splitStatements(m.Down)) > 1) {
+			return nil, fmt.Errorf("migration %v has more than one statement", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// splitStatements splits a migration script into individual statements, since
+// the MySQL driver doesn't allow multiple statements per Exec by default.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+// withMigrationLock runs fn on a single connection while holding the migration
+// lock. SQLite databases are only opened by one process at a time, so only
+// MySQL needs an explicit lock.
+func (s *SQLStore) withMigrationLock(fn func(conn *sqlx.Conn) error) error {
+	ctx := context.Background()
+	conn, err := s.db.Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if s.dialect == dialectMySQL {
+		var locked int
+		if err := conn.QueryRowxContext(ctx, "SELECT GET_LOCK(?, ?)", migrationLockName, migrationLockTimeout).Scan(&locked); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		if locked != 1 {
+			return errors.New("timed out waiting for migration lock")
+		}
+		defer conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", migrationLockName)
+	}
+
+	createTable := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT NOT NULL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`
+	if _, err := conn.ExecContext(ctx, createTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func appliedMigrations(q sqlx.QueryerContext) (map[int]string, error) {
+	var rows []struct {
+		Version   int    `db:"version"`
+		AppliedAt string `db:"applied_at"`
+	}
+	if err := sqlx.SelectContext(context.Background(), q, &rows, "SELECT version, applied_at FROM schema_migrations"); err != nil {
+		return nil, fmt.Errorf("failed to select applied migrations: %w", err)
+	}
+	applied := make(map[int]string, len(rows))
+	for _, r := range rows {
+		applied[r.Version] = r.AppliedAt
+	}
+	return applied, nil
+}
+
+// runMigration runs a migration script and records the change with the given
+// schema_migrations statement, in a single transaction. On SQLite, a failed
+// migration therefore leaves nothing behind. On MySQL, the statement has
+// already been committed, so the transaction only covers the bookkeeping.
+func runMigration(conn *sqlx.Conn, script, record string, version int, args ...interface{}) error {
+	ctx := context.Background()
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range splitStatements(script) {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return fmt.Errorf("failed to record migration %v: %w", version, err)
+	}
+	return tx.Commit()
+}
+
+// MigrateUp applies all pending migrations in order.
+func (s *SQLStore) MigrateUp() error {
+	migrations, err := loadMigrations(s.dialect)
+	if err != nil {
+		return err
+	}
+
+	return s.withMigrationLock(func(conn *sqlx.Conn) error {
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			record := "INSERT INTO schema_migrations (version, name) VALUES (?, ?)"
+			if err := runMigration(conn, m.Up, record, m.Version, m.Version, m.Name); err != nil {
+				return fmt.Errorf("migration %v_%v failed: %w", m.Version, m.Name, err)
+			}
+			log.Printf("Applied migration %v_%v", m.Version, m.Name)
+		}
+		return nil
+	})
+}
+
+// MigrateDown reverts the given number of most recently applied migrations.
+func (s *SQLStore) MigrateDown(steps int) error {
+	migrations, err := loadMigrations(s.dialect)
+	if err != nil {
+		return err
+	}
+
+	return s.withMigrationLock(func(conn *sqlx.Conn) error {
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if m.Down == "" {
+				return fmt.Errorf("migration %v_%v cannot be reverted", m.Version, m.Name)
+			}
+			record := "DELETE FROM schema_migrations WHERE version = ?"
+			if err := runMigration(conn, m.Down, record, m.Version, m.Version); err != nil {
+				return fmt.Errorf("reverting migration %v_%v failed: %w", m.Version, m.Name, err)
+			}
+			log.Printf("Reverted migration %v_%v", m.Version, m.Name)
+			steps--
+		}
+		return nil
+	})
+}
+
+// MigrationStatus lists every known migration and whether it has been applied.
+// It only reads, so it neither creates schema_migrations nor waits for the
+// migration lock.
+func (s *SQLStore) MigrationStatus() ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(s.dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := s.hasMigrationsTable()
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int]string)
+	if exists {
+		if applied, err = appliedMigrations(s.db); err != nil {
+			return nil, err
+		}
+	}
+
+	status := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		appliedAt, ok := applied[m.Version]
+		status = append(status, MigrationStatus{
+			Version:   m.Version,
+			Name:      m.Name,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+	}
+	return status, nil
+}
+
+// hasMigrationsTable reports whether schema_migrations exists, which it
+// doesn't until migrations are first applied.
+func (s *SQLStore) hasMigrationsTable() (bool, error) {
+	query := `
+		SELECT count(*) FROM information_schema.tables
+		WHERE table_schema = DATABASE() AND table_name = 'schema_migrations'
+	`
+	if s.dialect == dialectSQLite {
+		query = "SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'"
+	}
+
+	var n int
+	if err := s.db.Get(&n, query); err != nil {
+		return false, fmt.Errorf("failed to check for schema_migrations table: %w", err)
+	}
+	return n > 0, nil
+}
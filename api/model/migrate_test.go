@@ -0,0 +1,111 @@
+package model
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestMigrateUpDown(t *testing.T) {
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	status, err := store.MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range status {
+		if m.Applied {
+			t.Errorf("migration %v applied before migrating", m.Version)
+		}
+	}
+	if exists, err := store.hasMigrationsTable(); err != nil || exists {
+		t.Fatalf("status created schema_migrations (exists %v, err %v)", exists, err)
+	}
+
+	if err := store.MigrateUp(); err != nil {
+		t.Fatal(err)
+	}
+	status, err = store.MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range status {
+		if !m.Applied {
+			t.Errorf("migration %v not applied after migrating", m.Version)
+		}
+	}
+
+	if err := store.MigrateDown(len(status)); err != nil {
+		t.Fatal(err)
+	}
+	var tables []string
+	if err := store.db.Select(&tables, "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'"); err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 1 || tables[0] != "schema_migrations" {
+		t.Errorf("tables left after reverting every migration: %v", tables)
+	}
+
+	if err := store.MigrateUp(); err != nil {
+		t.Fatalf("migrating again after reverting: %v", err)
+	}
+}
+
+func TestFailedMigrationLeavesNothingBehind(t *testing.T) {
+	store := newTestSQLiteStore(t)
+
+	script := `
+		CREATE TABLE half_applied (id INTEGER);
+		INSERT INTO no_such_table VALUES (1);
+	`
+	record := "INSERT INTO schema_migrations (version, name) VALUES (?, ?)"
+	err := store.withMigrationLock(func(conn *sqlx.Conn) error {
+		return runMigration(conn, script, record, 9999, 9999, "broken")
+	})
+	if err == nil {
+		t.Fatal("broken migration succeeded")
+	}
+
+	var n int
+	if err := store.db.Get(&n, "SELECT count(*) FROM sqlite_master WHERE name = 'half_applied'"); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Error("table created by the failed migration was kept")
+	}
+	if err := store.db.Get(&n, "SELECT count(*) FROM schema_migrations WHERE version = 9999"); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Error("failed migration was recorded")
+	}
+}
+
+func TestMigrationsMatchBetweenDialects(t *testing.T) {
+	mysql, err := loadMigrations(dialectMySQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlite, err := loadMigrations(dialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mysql) != len(sqlite) {
+		t.Fatalf("%v MySQL migrations, but %v SQLite migrations", len(mysql), len(sqlite))
+	}
+	for i := range mysql {
+		if mysql[i].Version != sqlite[i].Version || mysql[i].Name != sqlite[i].Name {
+			t.Errorf("MySQL migration %v_%v doesn't match SQLite migration %v_%v",
+				mysql[i].Version, mysql[i].Name, sqlite[i].Version, sqlite[i].Name)
+		}
+		if mysql[i].Down == "" || sqlite[i].Down == "" {
+			t.Errorf("migration %v_%v can't be reverted", mysql[i].Version, mysql[i].Name)
+		}
+	}
+}
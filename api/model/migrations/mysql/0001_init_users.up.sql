@@ -0,0 +1,12 @@
+-- Baseline schema. Uses IF NOT EXISTS so that it is a no-op on databases that
+-- were created before migrations existed.
+CREATE TABLE IF NOT EXISTS users (
+	id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	email VARCHAR(255) NOT NULL,
+	created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	last_logged_in DATETIME NULL,
+	active TINYINT(1) NOT NULL DEFAULT 1,
+	admin TINYINT(1) NOT NULL DEFAULT 0,
+	UNIQUE KEY users_email (email)
+);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS shortcuts (
+	id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	code VARCHAR(255) NOT NULL,
+	url TEXT NOT NULL,
+	created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	created_by INT NOT NULL,
+	updated DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_by INT NOT NULL,
+	UNIQUE KEY shortcuts_code (code)
+);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS visits (
+	id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	timestamp DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	shortcut_id INT NOT NULL,
+	ip_address VARCHAR(64) NOT NULL DEFAULT '',
+	path TEXT NOT NULL,
+	referer TEXT NOT NULL,
+	user_agent TEXT NOT NULL,
+	KEY visits_shortcut_id_timestamp (shortcut_id, timestamp),
+	KEY visits_timestamp (timestamp)
+);
@@ -0,0 +1,5 @@
+ALTER TABLE shortcuts
+	DROP KEY shortcuts_expired_expires_at,
+	DROP COLUMN starts_at,
+	DROP COLUMN expires_at,
+	DROP COLUMN expired;
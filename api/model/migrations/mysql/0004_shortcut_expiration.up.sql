@@ -0,0 +1,5 @@
+ALTER TABLE shortcuts
+	ADD COLUMN starts_at DATETIME NULL,
+	ADD COLUMN expires_at DATETIME NULL,
+	ADD COLUMN expired TINYINT(1) NOT NULL DEFAULT 0,
+	ADD KEY shortcuts_expired_expires_at (expired, expires_at);
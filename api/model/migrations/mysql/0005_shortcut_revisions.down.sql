@@ -0,0 +1 @@
+DROP TABLE shortcut_revisions;
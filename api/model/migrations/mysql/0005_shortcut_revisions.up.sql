@@ -0,0 +1,14 @@
+CREATE TABLE shortcut_revisions (
+	id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	shortcut_id INT NOT NULL,
+	revision INT NOT NULL,
+	action VARCHAR(16) NOT NULL,
+	code VARCHAR(255) NOT NULL,
+	url TEXT NOT NULL,
+	starts_at DATETIME NULL,
+	expires_at DATETIME NULL,
+	changed DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	changed_by INT NOT NULL,
+	UNIQUE KEY shortcut_revisions_shortcut_id_revision (shortcut_id, revision),
+	KEY shortcut_revisions_action (action)
+);
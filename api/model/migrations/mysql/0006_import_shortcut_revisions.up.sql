@@ -0,0 +1,4 @@
+-- Give existing shortcuts a starting point in their history.
+INSERT INTO shortcut_revisions (shortcut_id, revision, action, code, url, starts_at, expires_at, changed, changed_by)
+SELECT id, 1, 'imported', code, url, starts_at, expires_at, updated, updated_by
+FROM shortcuts;
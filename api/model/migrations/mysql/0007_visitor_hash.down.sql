@@ -0,0 +1,3 @@
+ALTER TABLE visits
+	ADD COLUMN ip_address VARCHAR(64) NOT NULL DEFAULT '',
+	DROP COLUMN visitor_hash;
@@ -0,0 +1,6 @@
+-- Raw IP addresses are replaced by a hash that changes daily. Existing
+-- addresses are discarded rather than hashed, since the hash key isn't
+-- available to migrations.
+ALTER TABLE visits
+	ADD COLUMN visitor_hash VARCHAR(64) NOT NULL DEFAULT '',
+	DROP COLUMN ip_address;
@@ -0,0 +1 @@
+DROP TABLE visit_daily_counts;
@@ -0,0 +1,11 @@
+CREATE TABLE visit_daily_counts (
+	shortcut_id INT NOT NULL,
+	day DATE NOT NULL,
+	clicks INT NOT NULL,
+	uniques INT NOT NULL,
+	top_referers TEXT NOT NULL,
+	top_user_agents TEXT NOT NULL,
+	top_sources TEXT NOT NULL,
+	PRIMARY KEY (shortcut_id, day),
+	KEY visit_daily_counts_day (day)
+);
@@ -0,0 +1 @@
+DROP TABLE visit_rollup_state;
@@ -0,0 +1,5 @@
+-- Single row recording the last day that has been rolled up.
+CREATE TABLE visit_rollup_state (
+	id INT NOT NULL PRIMARY KEY,
+	rolled_up_through DATE NOT NULL
+);
@@ -0,0 +1,9 @@
+-- Codes that were requested but don't exist, so that commonly tried codes can
+-- be created.
+CREATE TABLE missed_codes (
+	code VARCHAR(255) NOT NULL PRIMARY KEY,
+	count INT NOT NULL,
+	first_seen DATETIME NOT NULL,
+	last_seen DATETIME NOT NULL,
+	KEY missed_codes_count (count)
+);
@@ -0,0 +1,2 @@
+-- Prefix shortcuts also match longer paths, forwarding the rest of the path.
+ALTER TABLE shortcuts ADD COLUMN is_prefix TINYINT(1) NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE shortcut_revisions ADD COLUMN is_prefix TINYINT(1) NOT NULL DEFAULT 0;
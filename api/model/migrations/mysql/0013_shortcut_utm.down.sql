@@ -0,0 +1,5 @@
+ALTER TABLE shortcuts
+	DROP COLUMN utm_mode,
+	DROP COLUMN utm_source,
+	DROP COLUMN utm_medium,
+	DROP COLUMN utm_campaign;
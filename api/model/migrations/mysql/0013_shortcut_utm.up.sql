@@ -0,0 +1,7 @@
+-- How redirects are tagged with UTM parameters: inherit the global defaults,
+-- use the custom values, or don't tag at all.
+ALTER TABLE shortcuts
+	ADD COLUMN utm_mode VARCHAR(16) NOT NULL DEFAULT 'inherit',
+	ADD COLUMN utm_source VARCHAR(255) NOT NULL DEFAULT '',
+	ADD COLUMN utm_medium VARCHAR(255) NOT NULL DEFAULT '',
+	ADD COLUMN utm_campaign VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,5 @@
+ALTER TABLE shortcut_revisions
+	ADD COLUMN utm_mode VARCHAR(16) NOT NULL DEFAULT 'inherit',
+	ADD COLUMN utm_source VARCHAR(255) NOT NULL DEFAULT '',
+	ADD COLUMN utm_medium VARCHAR(255) NOT NULL DEFAULT '',
+	ADD COLUMN utm_campaign VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- The HTTP status redirects are sent with. Permanent redirects are cached by
+-- browsers, so they have to be chosen explicitly.
+ALTER TABLE shortcuts ADD COLUMN redirect_status SMALLINT NOT NULL DEFAULT 302;
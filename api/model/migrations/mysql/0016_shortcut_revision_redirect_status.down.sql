@@ -0,0 +1 @@
+ALTER TABLE shortcut_revisions DROP COLUMN redirect_status;
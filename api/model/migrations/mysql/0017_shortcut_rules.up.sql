@@ -0,0 +1,2 @@
+-- Routing rules are stored as a JSON list, or NULL if a shortcut has none.
+ALTER TABLE shortcuts ADD COLUMN rules TEXT NULL;
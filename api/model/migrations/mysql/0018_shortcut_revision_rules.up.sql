@@ -0,0 +1 @@
+ALTER TABLE shortcut_revisions ADD COLUMN rules TEXT NULL;
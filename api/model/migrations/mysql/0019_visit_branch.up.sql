@@ -0,0 +1,2 @@
+-- The rule a visitor was sent to.
+ALTER TABLE visits ADD COLUMN branch VARCHAR(64) NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+ALTER TABLE shortcuts
+	DROP COLUMN variants,
+	DROP COLUMN sticky_variants;
@@ -0,0 +1,4 @@
+-- Variants are stored as a JSON list, or NULL if a shortcut has none.
+ALTER TABLE shortcuts
+	ADD COLUMN variants TEXT NULL,
+	ADD COLUMN sticky_variants TINYINT(1) NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+ALTER TABLE shortcut_revisions
+	DROP COLUMN variants,
+	DROP COLUMN sticky_variants;
@@ -0,0 +1,3 @@
+ALTER TABLE shortcut_revisions
+	ADD COLUMN variants TEXT NULL,
+	ADD COLUMN sticky_variants TINYINT(1) NOT NULL DEFAULT 0;
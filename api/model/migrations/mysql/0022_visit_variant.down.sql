@@ -0,0 +1 @@
+ALTER TABLE visits DROP COLUMN variant;
@@ -0,0 +1,2 @@
+-- The variant a visitor was sent to, and clicks per variant in rollups.
+ALTER TABLE visits ADD COLUMN variant VARCHAR(32) NOT NULL DEFAULT '';
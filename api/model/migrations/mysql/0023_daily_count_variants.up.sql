@@ -0,0 +1 @@
+ALTER TABLE visit_daily_counts ADD COLUMN top_variants TEXT NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE shortcuts
+	DROP COLUMN visibility,
+	DROP COLUMN password_hash;
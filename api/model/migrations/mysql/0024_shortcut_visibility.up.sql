@@ -0,0 +1,5 @@
+-- Who can follow a shortcut: anyone, anyone with the password, or logged in
+-- members. Passwords are stored as bcrypt hashes.
+ALTER TABLE shortcuts
+	ADD COLUMN visibility VARCHAR(16) NOT NULL DEFAULT 'public',
+	ADD COLUMN password_hash VARCHAR(255) NOT NULL DEFAULT '';
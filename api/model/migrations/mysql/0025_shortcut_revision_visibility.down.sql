@@ -0,0 +1,3 @@
+ALTER TABLE shortcut_revisions
+	DROP COLUMN visibility,
+	DROP COLUMN password_hash;
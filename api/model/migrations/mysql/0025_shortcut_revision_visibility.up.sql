@@ -0,0 +1,3 @@
+ALTER TABLE shortcut_revisions
+	ADD COLUMN visibility VARCHAR(16) NOT NULL DEFAULT 'public',
+	ADD COLUMN password_hash VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+ALTER TABLE shortcuts
+	DROP COLUMN title,
+	DROP COLUMN interstitial;
@@ -0,0 +1,5 @@
+-- Shown on preview pages, which interstitial shortcuts always show before
+-- sending visitors on.
+ALTER TABLE shortcuts
+	ADD COLUMN title VARCHAR(255) NOT NULL DEFAULT '',
+	ADD COLUMN interstitial TINYINT(1) NOT NULL DEFAULT 0;
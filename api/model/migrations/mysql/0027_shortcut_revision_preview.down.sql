@@ -0,0 +1,3 @@
+ALTER TABLE shortcut_revisions
+	DROP COLUMN title,
+	DROP COLUMN interstitial;
@@ -0,0 +1,3 @@
+ALTER TABLE shortcut_revisions
+	ADD COLUMN title VARCHAR(255) NOT NULL DEFAULT '',
+	ADD COLUMN interstitial TINYINT(1) NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- How the visitor got the link, e.g. 'qr' for QR code scans.
+ALTER TABLE visits ADD COLUMN source VARCHAR(16) NOT NULL DEFAULT '';
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	email TEXT NOT NULL UNIQUE,
+	created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	last_logged_in DATETIME,
+	active BOOLEAN NOT NULL DEFAULT 1,
+	admin BOOLEAN NOT NULL DEFAULT 0
+);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS shortcuts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	code TEXT NOT NULL UNIQUE,
+	url TEXT NOT NULL,
+	created DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	created_by INTEGER NOT NULL,
+	updated DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_by INTEGER NOT NULL
+);
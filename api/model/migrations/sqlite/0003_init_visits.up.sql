@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS visits (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	timestamp DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	shortcut_id INTEGER NOT NULL,
+	ip_address TEXT NOT NULL DEFAULT '',
+	path TEXT NOT NULL DEFAULT '',
+	referer TEXT NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS visits_shortcut_id_timestamp ON visits (shortcut_id, timestamp);
+CREATE INDEX IF NOT EXISTS visits_timestamp ON visits (timestamp);
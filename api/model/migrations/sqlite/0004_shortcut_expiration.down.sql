@@ -0,0 +1,4 @@
+DROP INDEX shortcuts_expired_expires_at;
+ALTER TABLE shortcuts DROP COLUMN starts_at;
+ALTER TABLE shortcuts DROP COLUMN expires_at;
+ALTER TABLE shortcuts DROP COLUMN expired;
@@ -0,0 +1,4 @@
+ALTER TABLE shortcuts ADD COLUMN starts_at DATETIME;
+ALTER TABLE shortcuts ADD COLUMN expires_at DATETIME;
+ALTER TABLE shortcuts ADD COLUMN expired BOOLEAN NOT NULL DEFAULT 0;
+CREATE INDEX shortcuts_expired_expires_at ON shortcuts (expired, expires_at);
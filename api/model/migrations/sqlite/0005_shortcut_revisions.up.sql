@@ -0,0 +1,15 @@
+CREATE TABLE shortcut_revisions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	shortcut_id INTEGER NOT NULL,
+	revision INTEGER NOT NULL,
+	action TEXT NOT NULL,
+	code TEXT NOT NULL,
+	url TEXT NOT NULL,
+	starts_at DATETIME,
+	expires_at DATETIME,
+	changed DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	changed_by INTEGER NOT NULL,
+	UNIQUE (shortcut_id, revision)
+);
+
+CREATE INDEX shortcut_revisions_action ON shortcut_revisions (action);
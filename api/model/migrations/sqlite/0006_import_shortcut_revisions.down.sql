@@ -0,0 +1,2 @@
+-- Undoes the import, so that it can be run again.
+DELETE FROM shortcut_revisions WHERE action = 'imported';
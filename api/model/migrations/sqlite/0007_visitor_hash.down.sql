@@ -0,0 +1,2 @@
+ALTER TABLE visits ADD COLUMN ip_address TEXT NOT NULL DEFAULT '';
+ALTER TABLE visits DROP COLUMN visitor_hash;
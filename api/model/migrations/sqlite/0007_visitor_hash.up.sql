@@ -0,0 +1,2 @@
+ALTER TABLE visits ADD COLUMN visitor_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE visits DROP COLUMN ip_address;
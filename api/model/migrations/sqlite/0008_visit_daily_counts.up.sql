@@ -0,0 +1,12 @@
+CREATE TABLE visit_daily_counts (
+	shortcut_id INTEGER NOT NULL,
+	day DATE NOT NULL,
+	clicks INTEGER NOT NULL,
+	uniques INTEGER NOT NULL,
+	top_referers TEXT NOT NULL,
+	top_user_agents TEXT NOT NULL,
+	top_sources TEXT NOT NULL,
+	PRIMARY KEY (shortcut_id, day)
+);
+
+CREATE INDEX visit_daily_counts_day ON visit_daily_counts (day);
@@ -0,0 +1,4 @@
+CREATE TABLE visit_rollup_state (
+	id INTEGER PRIMARY KEY,
+	rolled_up_through DATE NOT NULL
+);
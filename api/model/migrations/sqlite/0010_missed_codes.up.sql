@@ -0,0 +1,8 @@
+CREATE TABLE missed_codes (
+	code TEXT NOT NULL PRIMARY KEY,
+	count INTEGER NOT NULL,
+	first_seen DATETIME NOT NULL,
+	last_seen DATETIME NOT NULL
+);
+
+CREATE INDEX missed_codes_count ON missed_codes (count);
@@ -0,0 +1 @@
+ALTER TABLE shortcuts ADD COLUMN is_prefix BOOLEAN NOT NULL DEFAULT 0;
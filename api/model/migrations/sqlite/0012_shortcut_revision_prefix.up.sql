@@ -0,0 +1 @@
+ALTER TABLE shortcut_revisions ADD COLUMN is_prefix BOOLEAN NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+ALTER TABLE shortcuts DROP COLUMN utm_campaign;
+ALTER TABLE shortcuts DROP COLUMN utm_medium;
+ALTER TABLE shortcuts DROP COLUMN utm_source;
+ALTER TABLE shortcuts DROP COLUMN utm_mode;
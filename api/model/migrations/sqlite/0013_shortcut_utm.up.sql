@@ -0,0 +1,4 @@
+ALTER TABLE shortcuts ADD COLUMN utm_mode TEXT NOT NULL DEFAULT 'inherit';
+ALTER TABLE shortcuts ADD COLUMN utm_source TEXT NOT NULL DEFAULT '';
+ALTER TABLE shortcuts ADD COLUMN utm_medium TEXT NOT NULL DEFAULT '';
+ALTER TABLE shortcuts ADD COLUMN utm_campaign TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+ALTER TABLE shortcut_revisions DROP COLUMN utm_campaign;
+ALTER TABLE shortcut_revisions DROP COLUMN utm_medium;
+ALTER TABLE shortcut_revisions DROP COLUMN utm_source;
+ALTER TABLE shortcut_revisions DROP COLUMN utm_mode;
@@ -0,0 +1 @@
+ALTER TABLE shortcuts DROP COLUMN redirect_status;
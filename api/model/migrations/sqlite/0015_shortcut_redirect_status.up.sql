@@ -0,0 +1 @@
+ALTER TABLE shortcuts ADD COLUMN redirect_status INTEGER NOT NULL DEFAULT 302;
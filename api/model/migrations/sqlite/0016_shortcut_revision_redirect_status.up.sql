@@ -0,0 +1 @@
+ALTER TABLE shortcut_revisions ADD COLUMN redirect_status INTEGER NOT NULL DEFAULT 302;
@@ -0,0 +1 @@
+ALTER TABLE shortcuts ADD COLUMN rules TEXT NULL;
@@ -0,0 +1 @@
+ALTER TABLE shortcut_revisions DROP COLUMN rules;
@@ -0,0 +1 @@
+ALTER TABLE visits ADD COLUMN branch TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE shortcuts DROP COLUMN sticky_variants;
+ALTER TABLE shortcuts DROP COLUMN variants;
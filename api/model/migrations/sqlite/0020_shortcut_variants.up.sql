@@ -0,0 +1,2 @@
+ALTER TABLE shortcuts ADD COLUMN variants TEXT NULL;
+ALTER TABLE shortcuts ADD COLUMN sticky_variants BOOLEAN NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE shortcut_revisions DROP COLUMN sticky_variants;
+ALTER TABLE shortcut_revisions DROP COLUMN variants;
@@ -0,0 +1,2 @@
+ALTER TABLE shortcut_revisions ADD COLUMN variants TEXT NULL;
+ALTER TABLE shortcut_revisions ADD COLUMN sticky_variants BOOLEAN NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE visits ADD COLUMN variant TEXT NOT NULL DEFAULT '';
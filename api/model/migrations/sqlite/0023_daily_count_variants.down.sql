@@ -0,0 +1 @@
+ALTER TABLE visit_daily_counts DROP COLUMN top_variants;
@@ -0,0 +1,2 @@
+ALTER TABLE shortcuts DROP COLUMN password_hash;
+ALTER TABLE shortcuts DROP COLUMN visibility;
@@ -0,0 +1,2 @@
+ALTER TABLE shortcuts ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public';
+ALTER TABLE shortcuts ADD COLUMN password_hash TEXT NOT NULL DEFAULT '';
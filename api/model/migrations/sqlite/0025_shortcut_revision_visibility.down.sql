@@ -0,0 +1,2 @@
+ALTER TABLE shortcut_revisions DROP COLUMN password_hash;
+ALTER TABLE shortcut_revisions DROP COLUMN visibility;
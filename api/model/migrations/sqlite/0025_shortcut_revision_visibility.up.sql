@@ -0,0 +1,2 @@
+ALTER TABLE shortcut_revisions ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public';
+ALTER TABLE shortcut_revisions ADD COLUMN password_hash TEXT NOT NULL DEFAULT '';
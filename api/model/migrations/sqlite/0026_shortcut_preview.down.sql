@@ -0,0 +1,2 @@
+ALTER TABLE shortcuts DROP COLUMN interstitial;
+ALTER TABLE shortcuts DROP COLUMN title;
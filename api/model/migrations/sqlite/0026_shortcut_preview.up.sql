@@ -0,0 +1,2 @@
+ALTER TABLE shortcuts ADD COLUMN title TEXT NOT NULL DEFAULT '';
+ALTER TABLE shortcuts ADD COLUMN interstitial BOOLEAN NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE shortcut_revisions DROP COLUMN interstitial;
+ALTER TABLE shortcut_revisions DROP COLUMN title;
@@ -0,0 +1,2 @@
+ALTER TABLE shortcut_revisions ADD COLUMN title TEXT NOT NULL DEFAULT '';
+ALTER TABLE shortcut_revisions ADD COLUMN interstitial BOOLEAN NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE visits ADD COLUMN source TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// MissedCode is a code that was requested but doesn't exist.
+type MissedCode struct {
+	Code      string `db:"code"`
+	Count     int64  `db:"count"`
+	FirstSeen string `db:"first_seen"`
+	LastSeen  string `db:"last_seen"`
+}
+
+// RecordMissedCodes adds to the number of times each code was missed.
+func (s *SQLStore) RecordMissedCodes(counts map[string]int64, at time.Time) error {
+	query := `
+		INSERT INTO missed_codes (code, count, first_seen, last_seen)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE count = count + VALUES(count), last_seen = VALUES(last_seen)
+	`
+	if s.dialect == dialectSQLite {
+		query = `
+			INSERT INTO missed_codes (code, count, first_seen, last_seen)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT (code) DO UPDATE SET count = count + excluded.count, last_seen = excluded.last_seen
+		`
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	ts := FormatTime(at)
+	for code, n := range counts {
+		if _, err := tx.Exec(query, code, n, ts, ts); err != nil {
+			return fmt.Errorf("error recording missed code: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListMissedCodes returns the most often missed codes that still don't exist.
+func (s *SQLStore) ListMissedCodes(limit int) ([]MissedCode, error) {
+	query := `
+		SELECT m.code, m.count, m.first_seen, m.last_seen
+		FROM missed_codes m
+		LEFT JOIN shortcuts s ON s.code = m.code
+		WHERE s.id IS NULL
+		ORDER BY m.count DESC, m.last_seen DESC
+		LIMIT ?
+	`
+
+	codes := make([]MissedCode, 0)
+	if err := s.db.Select(&codes, query, limit); err != nil {
+		return nil, fmt.Errorf("failed to select missed codes: %w", err)
+	}
+
+	return codes, nil
+}
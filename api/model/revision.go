@@ -0,0 +1,173 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Revision actions.
+const (
+	RevisionImported = "imported"
+	RevisionCreated  = "created"
+	RevisionUpdated  = "updated"
+	RevisionDeleted  = "deleted"
+	RevisionReverted = "reverted"
+	RevisionRestored = "restored"
+)
+
+// ErrConcurrentChange is returned when a shortcut was changed by someone else
+// at the same time, so that its revisions couldn't be numbered. The change can
+// be retried.
+var ErrConcurrentChange = errors.New("shortcut was changed at the same time, please try again")
+
+// ShortcutRevision is a snapshot of a shortcut taken after every change. Deleted
+// shortcuts keep their revisions so that they can be restored.
+type ShortcutRevision struct {
+	ID         int    `db:"id"`
+	ShortcutID int    `db:"shortcut_id"`
+	Revision   int    `db:"revision"`
+	Action     string `db:"action"`
+	ShortcutSettings
+	ChangedAt     string `db:"changed"`
+	ChangedBy     int    `db:"changed_by"`
+	ChangedByName string `db:"changed_by_name"`
+}
+
+// recordRevision snapshots the current state of a shortcut as its next revision.
+func recordRevision(tx *sqlx.Tx, shortcutID int, action string, changedBy int) error {
+	query := `
+		INSERT INTO shortcut_revisions (shortcut_id, revision, action, changed_by, ` + shortcutSettingsColumns + `)
+		SELECT id,
+		       (SELECT COALESCE(MAX(revision), 0) + 1 FROM shortcut_revisions WHERE shortcut_id = ?),
+		       ?, ?, ` + shortcutSettingsColumns + `
+		FROM shortcuts
+		WHERE id = ?
+	`
+
+	_, err := tx.Exec(query, shortcutID, action, changedBy, shortcutID)
+	if isUniqueViolation(err) {
+		return ErrConcurrentChange
+	}
+	if err != nil {
+		return fmt.Errorf("error recording shortcut revision: %w", err)
+	}
+
+	return nil
+}
+
+// lockShortcut locks a shortcut's row until tx ends, so that concurrent
+// changes to the same shortcut take turns numbering their revisions. SQLite
+// only allows one writer at a time, so only MySQL needs the lock.
+func (s *SQLStore) lockShortcut(tx *sqlx.Tx, id int) error {
+	if s.dialect != dialectMySQL {
+		return nil
+	}
+	var ids []int
+	if err := tx.Select(&ids, "SELECT id FROM shortcuts WHERE id = ? FOR UPDATE", id); err != nil {
+		return fmt.Errorf("failed to lock shortcut: %w", err)
+	}
+	return nil
+}
+
+var selectRevisions = `
+	SELECT r.id, shortcut_id, revision, action, changed, changed_by, IFNULL(u.name, '') as changed_by_name,
+	       ` + shortcutSettingsColumns + `
+	FROM shortcut_revisions r
+	LEFT JOIN users u on u.id = r.changed_by
+`
+
+func (s *SQLStore) ListShortcutRevisions(shortcutID int) ([]ShortcutRevision, error) {
+	query := selectRevisions + `
+		WHERE shortcut_id = ?
+		ORDER BY revision DESC
+	`
+
+	revisions := make([]ShortcutRevision, 0)
+	if err := s.db.Select(&revisions, query, shortcutID); err != nil {
+		return nil, fmt.Errorf("failed to select shortcut revisions: %w", err)
+	}
+
+	return revisions, nil
+}
+
+func (s *SQLStore) GetShortcutRevision(shortcutID, revision int) (ShortcutRevision, error) {
+	query := selectRevisions + `
+		WHERE shortcut_id = ? AND revision = ?
+	`
+
+	var revisions []ShortcutRevision
+	if err := s.db.Select(&revisions, query, shortcutID, revision); err != nil {
+		return ShortcutRevision{}, fmt.Errorf("failed to select shortcut revision: %w", err)
+	}
+	if revisions == nil {
+		return ShortcutRevision{}, nil
+	}
+
+	return revisions[0], nil
+}
+
+// ListDeletedShortcuts returns the final revision of every deleted shortcut
+// that has not been restored since.
+func (s *SQLStore) ListDeletedShortcuts() ([]ShortcutRevision, error) {
+	query := selectRevisions + `
+		WHERE action = 'deleted'
+		  AND revision = (SELECT MAX(revision) FROM shortcut_revisions m WHERE m.shortcut_id = r.shortcut_id)
+		  AND NOT EXISTS (SELECT 1 FROM shortcuts s WHERE s.id = r.shortcut_id)
+		ORDER BY changed DESC
+	`
+
+	revisions := make([]ShortcutRevision, 0)
+	if err := s.db.Select(&revisions, query); err != nil {
+		return nil, fmt.Errorf("failed to select deleted shortcuts: %w", err)
+	}
+
+	return revisions, nil
+}
+
+// RevertShortcut sets a shortcut back to the given revision, or recreates it
+// with its original ID if it has since been deleted.
+func (s *SQLStore) RevertShortcut(rev ShortcutRevision, userID int) error {
+	shortcut := Shortcut{
+		ID:               rev.ShortcutID,
+		CreatedBy:        userID,
+		UpdatedBy:        userID,
+		ShortcutSettings: rev.ShortcutSettings,
+	}
+	shortcut.Expired = shortcut.StateAt(time.Now()) == ShortcutExpired
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.lockShortcut(tx, rev.ShortcutID); err != nil {
+		return err
+	}
+
+	var exists int
+	if err := tx.Get(&exists, "SELECT count(*) FROM shortcuts WHERE id = ?", rev.ShortcutID); err != nil {
+		return fmt.Errorf("failed to check for shortcut: %w", err)
+	}
+
+	action, query := RevisionReverted, updateShortcut
+	if exists == 0 {
+		action, query = RevisionRestored, restoreShortcut
+	}
+
+	_, err = sqlx.NamedExec(tx, query, shortcut)
+	if isUniqueViolation(err) {
+		return ErrCodeTaken
+	}
+	if err != nil {
+		return fmt.Errorf("error reverting shortcut: %w", err)
+	}
+	if err := recordRevision(tx, shortcut.ID, action, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRevertRestoresAllSettings(t *testing.T) {
+	start := NewNullTime(time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC))
+	first := ShortcutSettings{
+		Code:           "join",
+		URL:            "https://example.com/join/{path}",
+		StartsAt:       start,
+		IsPrefix:       true,
+		UTMMode:        UTMCustom,
+		UTMSource:      "newsletter",
+		UTMMedium:      "email",
+		UTMCampaign:    "spring",
+		RedirectStatus: 308,
+		Rules:          RoutingRules{{Kind: RulePlatform, Value: "ios", URL: "https://example.com/ios"}},
+		Variants:       Variants{{Name: "a", URL: "https://example.com/a", Weight: 1}, {Name: "b", URL: "https://example.com/b", Weight: 2}},
+		StickyVariants: true,
+		Visibility:     VisibilityPassword,
+		PasswordHash:   "hash",
+		Title:          "Join us",
+		Interstitial:   true,
+	}
+	second := ShortcutSettings{
+		Code:           "join-us",
+		URL:            "https://example.com/other",
+		UTMMode:        UTMDisabled,
+		RedirectStatus: 302,
+		Visibility:     VisibilityPublic,
+	}
+
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			id, err := store.InsertShortcut(Shortcut{ShortcutSettings: first})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.UpdateShortcut(Shortcut{ID: int(id), ShortcutSettings: second}); err != nil {
+				t.Fatal(err)
+			}
+
+			checkSettings := func(when string, want ShortcutSettings) {
+				t.Helper()
+				shortcut, err := store.GetShortcutByID(int(id))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(shortcut.ShortcutSettings, want) {
+					t.Errorf("%v: settings = %+v, want %+v", when, shortcut.ShortcutSettings, want)
+				}
+			}
+			revert := func(revision int) {
+				t.Helper()
+				rev, err := store.GetShortcutRevision(int(id), revision)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := store.RevertShortcut(rev, 1); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			revert(1)
+			checkSettings("after reverting", first)
+
+			if err := store.DeleteShortcut(Shortcut{ID: int(id)}); err != nil {
+				t.Fatal(err)
+			}
+			revert(2)
+			checkSettings("after restoring", second)
+
+			revisions, err := store.ListShortcutRevisions(int(id))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var actions []string
+			for _, r := range revisions {
+				actions = append(actions, r.Action)
+			}
+			want := []string{RevisionRestored, RevisionDeleted, RevisionReverted, RevisionUpdated, RevisionCreated}
+			if !reflect.DeepEqual(actions, want) {
+				t.Errorf("actions = %v, want %v", actions, want)
+			}
+		})
+	}
+}
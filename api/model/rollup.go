@@ -0,0 +1,329 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const dayLayout = "2006-01-02"
+
+// DailyCount is a day of a shortcut's visits, rolled up so that raw visits can
+// be deleted once they are past the retention window.
+type DailyCount struct {
+	ShortcutID     int       `db:"shortcut_id"`
+	Day            string    `db:"day"`
+	Clicks         int64     `db:"clicks"`
+	UniqueVisitors int64     `db:"uniques"`
+	TopReferers    Breakdown `db:"top_referers"`
+	TopUserAgents  Breakdown `db:"top_user_agents"`
+	TopSources     Breakdown `db:"top_sources"`
+	TopVariants    Breakdown `db:"top_variants"`
+}
+
+// Breakdown is stored as JSON in rollup tables.
+type Breakdown []BreakdownItem
+
+func (b *Breakdown) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*b = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, b)
+	case string:
+		return json.Unmarshal([]byte(v), b)
+	default:
+		return fmt.Errorf("cannot scan %T into Breakdown", value)
+	}
+}
+
+func (b Breakdown) Value() (driver.Value, error) {
+	if b == nil {
+		b = Breakdown{}
+	}
+	v, err := json.Marshal(b)
+	return string(v), err
+}
+
+// startOfDay truncates t to midnight UTC.
+func startOfDay(t time.Time) time.Time {
+	return bucketStart(t, IntervalDay)
+}
+
+// RollupVisits aggregates a single day's visits per shortcut.
+func RollupVisits(visits []Visit, day time.Time) []DailyCount {
+	day = startOfDay(day)
+	rng := StatsRange{From: day, To: day.AddDate(0, 0, 1), Interval: IntervalDay}
+
+	counters := make(map[int]*statsCounter)
+	for _, v := range visits {
+		c, ok := counters[v.ShortcutID]
+		if !ok {
+			c = newStatsCounter(rng)
+			counters[v.ShortcutID] = c
+		}
+		c.add(v)
+	}
+
+	counts := make([]DailyCount, 0, len(counters))
+	for id, c := range counters {
+		stats := c.stats()
+		if stats.TotalClicks == 0 {
+			continue
+		}
+		counts = append(counts, DailyCount{
+			ShortcutID:     id,
+			Day:            day.Format(dayLayout),
+			Clicks:         stats.TotalClicks,
+			UniqueVisitors: stats.UniqueVisitors,
+			TopReferers:    stats.Referers,
+			TopUserAgents:  stats.UserAgents,
+			TopSources:     stats.Sources,
+			TopVariants:    stats.Variants,
+		})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		return counts[i].ShortcutID < counts[j].ShortcutID
+	})
+	return counts
+}
+
+// RollupVisitsThrough rolls up every day that hasn't been rolled up yet, up to
+// and including the given day. It returns the number of days rolled up.
+func RollupVisitsThrough(store Store, through time.Time) (int, error) {
+	through = startOfDay(through)
+
+	watermark, err := store.RolledUpThrough()
+	if err != nil {
+		return 0, err
+	}
+	var day time.Time
+	if watermark.Valid {
+		day = watermark.Time.AddDate(0, 0, 1)
+	} else {
+		first, err := store.FirstVisitTime()
+		if err != nil {
+			return 0, err
+		}
+		if !first.Valid {
+			return 0, nil
+		}
+		day = startOfDay(first.Time)
+	}
+
+	n := 0
+	for ; !day.After(through); day = day.AddDate(0, 0, 1) {
+		visits, err := store.ListVisits(0, day, day.AddDate(0, 0, 1))
+		if err != nil {
+			return n, err
+		}
+		if err := store.SaveDailyCounts(day, RollupVisits(visits, day)); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// ShortcutStats computes stats for a shortcut, using rollups for days that
+// have been rolled up and raw visits for the rest. Rollups only have daily
+// resolution, so hourly stats are always computed from raw visits and are
+// only available within the retention window. Other ranges must start and end
+// at midnight, as they do after Validate.
+func ShortcutStats(store Store, shortcutID int, rng StatsRange) (VisitStats, error) {
+	c := newStatsCounter(rng)
+
+	rawFrom := rng.From
+	if rng.Interval != IntervalHour {
+		watermark, err := store.RolledUpThrough()
+		if err != nil {
+			return VisitStats{}, err
+		}
+		if split := watermark.Time.AddDate(0, 0, 1); watermark.Valid && split.After(rng.From) {
+			if split.After(rng.To) {
+				split = rng.To
+			}
+			counts, err := store.ListDailyCounts(shortcutID, rng.From, split)
+			if err != nil {
+				return VisitStats{}, err
+			}
+			for _, dc := range counts {
+				c.addDaily(dc)
+			}
+			rawFrom = split
+		}
+	}
+
+	if rawFrom.Before(rng.To) {
+		visits, err := store.ListVisits(shortcutID, rawFrom, rng.To)
+		if err != nil {
+			return VisitStats{}, err
+		}
+		for _, v := range visits {
+			c.add(v)
+		}
+	}
+
+	return c.stats(), nil
+}
+
+// topPeriodStart returns the first day counted towards a top shortcuts period.
+// The period's first day is excluded, e.g. a week is the last seven days
+// including today.
+func topPeriodStart(period string, now time.Time) time.Time {
+	today := startOfDay(now)
+	var since time.Time
+	switch period {
+	case PeriodDay:
+		since = today.AddDate(0, 0, -1)
+	case PeriodWeek:
+		since = today.AddDate(0, 0, -7)
+	case PeriodMonth:
+		since = today.AddDate(0, -1, 0)
+	case PeriodYear:
+		since = today.AddDate(-1, 0, 0)
+	}
+	return since.AddDate(0, 0, 1)
+}
+
+func (s *SQLStore) FirstVisitTime() (NullTime, error) {
+	var first NullTime
+	if err := s.db.Get(&first, "SELECT MIN(timestamp) FROM visits"); err != nil {
+		return NullTime{}, fmt.Errorf("failed to select first visit: %w", err)
+	}
+	return first, nil
+}
+
+// RolledUpThrough returns the last day that has been rolled up, if any.
+func (s *SQLStore) RolledUpThrough() (NullTime, error) {
+	var days []NullTime
+	if err := s.db.Select(&days, "SELECT rolled_up_through FROM visit_rollup_state WHERE id = 1"); err != nil {
+		return NullTime{}, fmt.Errorf("failed to select rollup state: %w", err)
+	}
+	if days == nil {
+		return NullTime{}, nil
+	}
+	return days[0], nil
+}
+
+// SaveDailyCounts stores the rollups for a day and marks it as rolled up.
+// Saving a day again replaces its counts, so concurrent rollups are harmless.
+func (s *SQLStore) SaveDailyCounts(day time.Time, counts []DailyCount) error {
+	upsertCount := `
+		INSERT INTO visit_daily_counts (shortcut_id, day, clicks, uniques, top_referers, top_user_agents, top_sources,
+		                                top_variants)
+		VALUES (:shortcut_id, :day, :clicks, :uniques, :top_referers, :top_user_agents, :top_sources, :top_variants)
+		ON DUPLICATE KEY UPDATE
+			clicks = VALUES(clicks),
+			uniques = VALUES(uniques),
+			top_referers = VALUES(top_referers),
+			top_user_agents = VALUES(top_user_agents),
+			top_sources = VALUES(top_sources),
+			top_variants = VALUES(top_variants)
+	`
+	upsertState := `
+		INSERT INTO visit_rollup_state (id, rolled_up_through)
+		VALUES (1, ?)
+		ON DUPLICATE KEY UPDATE rolled_up_through = GREATEST(rolled_up_through, VALUES(rolled_up_through))
+	`
+	if s.dialect == dialectSQLite {
+		upsertCount = `
+			INSERT INTO visit_daily_counts (shortcut_id, day, clicks, uniques, top_referers, top_user_agents, top_sources,
+			                                top_variants)
+			VALUES (:shortcut_id, :day, :clicks, :uniques, :top_referers, :top_user_agents, :top_sources, :top_variants)
+			ON CONFLICT (shortcut_id, day) DO UPDATE SET
+				clicks = excluded.clicks,
+				uniques = excluded.uniques,
+				top_referers = excluded.top_referers,
+				top_user_agents = excluded.top_user_agents,
+				top_sources = excluded.top_sources,
+				top_variants = excluded.top_variants
+		`
+		upsertState = `
+			INSERT INTO visit_rollup_state (id, rolled_up_through)
+			VALUES (1, ?)
+			ON CONFLICT (id) DO UPDATE SET rolled_up_through = MAX(rolled_up_through, excluded.rolled_up_through)
+		`
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, dc := range counts {
+		if _, err := sqlx.NamedExec(tx, upsertCount, dc); err != nil {
+			return fmt.Errorf("error saving daily visit counts: %w", err)
+		}
+	}
+	if _, err := tx.Exec(upsertState, startOfDay(day).Format(dayLayout)); err != nil {
+		return fmt.Errorf("error saving rollup state: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// ListDailyCounts returns rollups for days that overlap the given range, where
+// to is exclusive. A shortcutID of 0 returns rollups for all shortcuts.
+func (s *SQLStore) ListDailyCounts(shortcutID int, from, to time.Time) ([]DailyCount, error) {
+	query := `
+		SELECT shortcut_id, day, clicks, uniques, top_referers, top_user_agents, top_sources, top_variants
+		FROM visit_daily_counts
+		WHERE day >= ? AND day < ?
+	`
+	end := startOfDay(to)
+	if end.Before(to) {
+		end = end.AddDate(0, 0, 1)
+	}
+	args := []interface{}{startOfDay(from).Format(dayLayout), end.Format(dayLayout)}
+	if shortcutID != 0 {
+		query += " AND shortcut_id = ?"
+		args = append(args, shortcutID)
+	}
+	query += " ORDER BY day"
+
+	var counts []DailyCount
+	if err := s.db.Select(&counts, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to select daily visit counts: %w", err)
+	}
+	for i := range counts {
+		if t, err := ParseTime(counts[i].Day); err == nil {
+			counts[i].Day = t.Format(dayLayout)
+		}
+	}
+
+	return counts, nil
+}
+
+// DeleteVisitsBefore deletes raw visits older than t, in batches to avoid
+// holding long locks. It returns the number of visits deleted.
+func (s *SQLStore) DeleteVisitsBefore(t time.Time) (int64, error) {
+	const batchSize = 10000
+
+	query := "DELETE FROM visits WHERE timestamp < ? LIMIT ?"
+	if s.dialect == dialectSQLite {
+		query = "DELETE FROM visits WHERE id IN (SELECT id FROM visits WHERE timestamp < ? LIMIT ?)"
+	}
+
+	var total int64
+	for {
+		res, err := s.db.Exec(query, FormatTime(t), batchSize)
+		if err != nil {
+			return total, fmt.Errorf("error deleting old visits: %w", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return total, fmt.Errorf("error getting number of deleted visits: %w", err)
+		}
+		total += n
+		if n < batchSize {
+			return total, nil
+		}
+	}
+}
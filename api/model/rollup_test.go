@@ -0,0 +1,62 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShortcutStatsSameBeforeAndAfterRollup(t *testing.T) {
+	day := startOfDay(time.Now()).AddDate(0, 0, -3)
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			err := store.InsertVisits([]Visit{
+				{ShortcutID: 1, Timestamp: day.Add(time.Hour).Format(timestampLayout), VisitorHash: "a"},
+				{ShortcutID: 1, Timestamp: day.Add(20 * time.Hour).Format(timestampLayout), VisitorHash: "b"},
+				{ShortcutID: 1, Timestamp: day.Add(30 * time.Hour).Format(timestampLayout), VisitorHash: "c"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// A range starting and ending in the middle of a day counts the
+			// whole of both days.
+			rng := StatsRange{From: day.Add(12 * time.Hour), To: day.Add(36 * time.Hour)}
+			if err := rng.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			if !rng.From.Equal(day) || !rng.To.Equal(day.AddDate(0, 0, 2)) {
+				t.Fatalf("range = %v to %v, want whole days", rng.From, rng.To)
+			}
+
+			before, err := ShortcutStats(store, 1, rng)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := RollupVisitsThrough(store, day); err != nil {
+				t.Fatal(err)
+			}
+			after, err := ShortcutStats(store, 1, rng)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if before.TotalClicks != 3 || after.TotalClicks != 3 {
+				t.Errorf("clicks = %v before the rollup and %v after, want 3", before.TotalClicks, after.TotalClicks)
+			}
+			if before.UniqueVisitors != 3 || after.UniqueVisitors != 3 {
+				t.Errorf("uniques = %v before the rollup and %v after, want 3", before.UniqueVisitors, after.UniqueVisitors)
+			}
+		})
+	}
+}
+
+func TestHourlyStatsRangeIsNotRounded(t *testing.T) {
+	from := time.Date(2026, 3, 2, 10, 30, 0, 0, time.UTC)
+	rng := StatsRange{From: from, To: from.Add(3 * time.Hour), Interval: IntervalHour}
+	if err := rng.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !rng.From.Equal(from) || !rng.To.Equal(from.Add(3*time.Hour)) {
+		t.Errorf("range = %v to %v", rng.From, rng.To)
+	}
+}
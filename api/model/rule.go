@@ -0,0 +1,79 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Routing rule kinds.
+const (
+	RulePlatform = "platform"
+	RuleLanguage = "language"
+	RuleCountry  = "country"
+)
+
+// BranchDefault is recorded on visits to shortcuts with routing rules when
+// none of the rules matched.
+const BranchDefault = "default"
+
+// RoutingRule sends visitors whose platform, preferred language or country is
+// Value to URL instead of the shortcut's URL.
+type RoutingRule struct {
+	Kind  string
+	Value string
+	URL   string
+}
+
+// Branch identifies the rule in visits, e.g. "platform:ios".
+func (r RoutingRule) Branch() string {
+	return r.Kind + ":" + r.Value
+}
+
+// RoutingRules are a shortcut's rules in the order they are tried. They are
+// stored as JSON in a single column, so that revisions keep them along with
+// the rest of the shortcut.
+type RoutingRules []RoutingRule
+
+func (r *RoutingRules) Scan(value interface{}) error {
+	return scanJSONList(value, (*[]RoutingRule)(r), "RoutingRules")
+}
+
+// scanJSONList scans a nullable column holding a JSON list into dest, which
+// is left nil if the column is NULL or empty.
+func scanJSONList(value interface{}, dest interface{}, name string) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into %v", value, name)
+	}
+	if len(b) == 0 {
+		b = []byte("null")
+	}
+	return json.Unmarshal(b, dest)
+}
+
+func (r RoutingRules) Value() (driver.Value, error) {
+	if len(r) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal([]RoutingRule(r))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// MarshalJSON encodes shortcuts without rules as an empty list rather than
+// null.
+func (r RoutingRules) MarshalJSON() ([]byte, error) {
+	if r == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]RoutingRule(r))
+}
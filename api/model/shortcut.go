@@ -3,19 +3,105 @@ package model
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
 
+// ErrCodeTaken is returned when saving a shortcut whose code is already used by
+// another shortcut.
+var ErrCodeTaken = errors.New("shortcut code is already taken")
+
+// ShortcutSettings are the fields of a shortcut that users edit. Every revision
+// keeps a copy of them, so that shortcuts can be reverted.
+type ShortcutSettings struct {
+	Code string `db:"code"`
+	URL  string `db:"url"`
+	// StartsAt and ExpiresAt optionally limit when the shortcut redirects.
+	StartsAt  NullTime `db:"starts_at"`
+	ExpiresAt NullTime `db:"expires_at"`
+	// IsPrefix makes the shortcut also match longer paths, e.g. "drive/abc"
+	// for the code "drive". The rest of the path is appended to the URL, or
+	// replaces a {path} placeholder in it.
+	IsPrefix bool `db:"is_prefix"`
+	// UTMMode controls how redirects are tagged with UTM parameters. In
+	// custom mode, the UTM fields override the global defaults.
+	UTMMode     string `db:"utm_mode"`
+	UTMSource   string `db:"utm_source"`
+	UTMMedium   string `db:"utm_medium"`
+	UTMCampaign string `db:"utm_campaign"`
+	// RedirectStatus is the HTTP status redirects are sent with: 301, 302,
+	// 307 or 308.
+	RedirectStatus int `db:"redirect_status"`
+	// Rules send some visitors elsewhere, depending on their platform,
+	// language or country. Visitors that match no rule go to URL.
+	Rules RoutingRules `db:"rules"`
+	// Variants split visitors that match no rule between several URLs. With
+	// StickyVariants, a cookie keeps sending each visitor to the same one.
+	Variants       Variants `db:"variants"`
+	StickyVariants bool     `db:"sticky_variants"`
+	// Visibility controls who can follow the shortcut. Password protected
+	// shortcuts ask for the password whose bcrypt hash is PasswordHash, which
+	// is never sent to clients.
+	Visibility   string `db:"visibility"`
+	PasswordHash string `db:"password_hash" json:"-"`
+	// Title is shown on the shortcut's preview page. With Interstitial set,
+	// visitors always see the preview page, which then counts down and
+	// sends them on. Visits are only counted once they continue.
+	Title        string `db:"title"`
+	Interstitial bool   `db:"interstitial"`
+}
+
+// shortcutSettingsColumns are the columns of ShortcutSettings, which both the
+// shortcuts and the shortcut_revisions tables have.
+const shortcutSettingsColumns = "code, url, starts_at, expires_at, is_prefix, " +
+	"utm_mode, utm_source, utm_medium, utm_campaign, " +
+	"redirect_status, rules, variants, sticky_variants, " +
+	"visibility, password_hash, title, interstitial"
+
 type Shortcut struct {
 	ID            int    `db:"id"`
-	Code          string `db:"code"`
-	URL           string `db:"url"`
 	CreatedAt     string `db:"created"`
 	CreatedBy     int    `db:"created_by"` // TODO: consider joining user table to get user name
 	UpdatedAt     string `db:"updated"`
 	UpdatedBy     int    `db:"updated_by"`
 	UpdatedByName string `db:"updated_by_name"`
+	ShortcutSettings
+	// Expired is set by the expiration sweeper once ExpiresAt has passed.
+	Expired bool `db:"expired"`
+}
+
+// Shortcut visibilities.
+const (
+	VisibilityPublic   = "public"
+	VisibilityPassword = "password"
+	VisibilityMembers  = "members"
+)
+
+// UTM modes.
+const (
+	UTMInherit  = "inherit"
+	UTMCustom   = "custom"
+	UTMDisabled = "disabled"
+)
+
+// Shortcut states, as returned by Shortcut.StateAt.
+const (
+	ShortcutScheduled = "scheduled"
+	ShortcutActive    = "active"
+	ShortcutExpired   = "expired"
+)
+
+// StateAt reports whether the shortcut is scheduled, active or expired at t.
+func (s Shortcut) StateAt(t time.Time) string {
+	if s.ExpiresAt.Valid && !t.Before(s.ExpiresAt.Time) {
+		return ShortcutExpired
+	}
+	if s.StartsAt.Valid && t.Before(s.StartsAt.Time) {
+		return ShortcutScheduled
+	}
+	return ShortcutActive
 }
 
 type ListShortcutOptions struct {
@@ -24,15 +110,53 @@ type ListShortcutOptions struct {
 	Page  int
 }
 
-func GetShortcutByCode(db *sqlx.DB, code string) (Shortcut, error) {
-	query := `
-		SELECT id, code, url, created, created_by, updated, updated_by
+var (
+	selectShortcut = `
+		SELECT id, created, created_by, updated, updated_by, expired, ` + shortcutSettingsColumns + `
 		FROM shortcuts
-		WHERE code = ?
 	`
+	insertShortcut = `
+		INSERT INTO shortcuts (created_by, updated_by, expired, ` + shortcutSettingsColumns + `)
+		VALUES (:created_by, :updated_by, :expired, ` + namedParams(shortcutSettingsColumns) + `)
+	`
+	// restoreShortcut recreates a deleted shortcut with its original ID.
+	restoreShortcut = `
+		INSERT INTO shortcuts (id, created_by, updated_by, expired, ` + shortcutSettingsColumns + `)
+		VALUES (:id, :created_by, :updated_by, :expired, ` + namedParams(shortcutSettingsColumns) + `)
+	`
+	updateShortcut = `
+		UPDATE shortcuts
+		SET ` + namedAssignments(shortcutSettingsColumns) + `,
+		    expired = :expired,
+		    updated = CURRENT_TIMESTAMP,
+		    updated_by = :updated_by
+		WHERE id = :id
+	`
+)
+
+// namedParams turns a list of columns into named parameters for sqlx, e.g.
+// ":code, :url" for "code, url".
+func namedParams(columns string) string {
+	params := strings.Split(columns, ", ")
+	for i, column := range params {
+		params[i] = ":" + column
+	}
+	return strings.Join(params, ", ")
+}
 
+// namedAssignments turns a list of columns into the assignments of an UPDATE,
+// e.g. "code = :code, url = :url" for "code, url".
+func namedAssignments(columns string) string {
+	assignments := strings.Split(columns, ", ")
+	for i, column := range assignments {
+		assignments[i] = column + " = :" + column
+	}
+	return strings.Join(assignments, ", ")
+}
+
+func (s *SQLStore) getShortcut(where string, arg interface{}) (Shortcut, error) {
 	var shortcuts []Shortcut
-	if err := db.Select(&shortcuts, query, code); err != nil {
+	if err := s.db.Select(&shortcuts, selectShortcut+where, arg); err != nil {
 		return Shortcut{}, fmt.Errorf("failed to select shortcut: %w", err)
 	}
 	if shortcuts == nil {
@@ -42,7 +166,15 @@ func GetShortcutByCode(db *sqlx.DB, code string) (Shortcut, error) {
 	return shortcuts[0], nil
 }
 
-func CountShortcuts(db *sqlx.DB, code string) (int, error) {
+func (s *SQLStore) GetShortcutByCode(code string) (Shortcut, error) {
+	return s.getShortcut("WHERE code = ?", code)
+}
+
+func (s *SQLStore) GetShortcutByID(id int) (Shortcut, error) {
+	return s.getShortcut("WHERE id = ?", id)
+}
+
+func (s *SQLStore) CountShortcuts(code string) (int, error) {
 	var total int
 	query := "SELECT count(*) FROM shortcuts"
 	var args []interface{}
@@ -50,19 +182,20 @@ func CountShortcuts(db *sqlx.DB, code string) (int, error) {
 		query += " WHERE code like ?"
 		args = []interface{}{code + "%"}
 	}
-	err := db.QueryRowx(query, args...).Scan(&total)
+	err := s.db.QueryRowx(query, args...).Scan(&total)
 	if err != nil {
 		return 0, fmt.Errorf("failed to count total shortcut rows: %w", err)
 	}
 	return total, nil
 }
 
-func ListShortcuts(db *sqlx.DB, opts ListShortcutOptions) ([]Shortcut, int, error) {
+func (s *SQLStore) ListShortcuts(opts ListShortcutOptions) ([]Shortcut, int, error) {
 	// TODO: join user name to display in UI?
 	query := `
-		SELECT s.id, code, url, s.created, created_by, updated, updated_by, u.name as updated_by_name
+		SELECT s.id, s.created, created_by, updated, updated_by, COALESCE(u.name, '') as updated_by_name,
+		       expired, ` + shortcutSettingsColumns + `
 		FROM shortcuts s
-		JOIN users u on u.id = s.updated_by
+		LEFT JOIN users u on u.id = s.updated_by
 	`
 	var args []interface{}
 
@@ -80,14 +213,14 @@ func ListShortcuts(db *sqlx.DB, opts ListShortcutOptions) ([]Shortcut, int, erro
 	}
 
 	var shortcuts []Shortcut
-	if err := db.Select(&shortcuts, query, args...); err != nil {
+	if err := s.db.Select(&shortcuts, query, args...); err != nil {
 		return nil, 0, fmt.Errorf("failed to select shortcuts: %w", err)
 	}
 	if shortcuts == nil {
 		return make([]Shortcut, 0), 0, nil
 	}
 
-	total, err := CountShortcuts(db, opts.Code)
+	total, err := s.CountShortcuts(opts.Code)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -95,13 +228,17 @@ func ListShortcuts(db *sqlx.DB, opts ListShortcutOptions) ([]Shortcut, int, erro
 	return shortcuts, total, nil
 }
 
-func InsertShortcut(db *sqlx.DB, shortcut Shortcut) (int64, error) {
-	query := `
-		INSERT INTO shortcuts (code, url, created_by, updated_by)
-		VALUES (:code, :url, :created_by, :updated_by) 
-	`
+func (s *SQLStore) InsertShortcut(shortcut Shortcut) (int64, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
 
-	res, err := sqlx.NamedExec(db, query, shortcut)
+	res, err := sqlx.NamedExec(tx, insertShortcut, shortcut)
+	if isUniqueViolation(err) {
+		return 0, ErrCodeTaken
+	}
 	if err != nil {
 		return 0, fmt.Errorf("error inserting shortcut: %w", err)
 	}
@@ -111,45 +248,103 @@ func InsertShortcut(db *sqlx.DB, shortcut Shortcut) (int64, error) {
 		return 0, fmt.Errorf("error getting id of inserted shortcut: %w", err)
 	}
 
+	if err := recordRevision(tx, int(id), RevisionCreated, shortcut.CreatedBy); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error committing shortcut: %w", err)
+	}
+
 	return id, nil
 }
 
-func UpdateShortcut(db *sqlx.DB, shortcut Shortcut) error {
-	query := `
-		UPDATE shortcuts
-		SET code = :code,
-		    url = :url,
-		    updated = CURRENT_TIMESTAMP,
-		    updated_by = :updated_by
-		WHERE id = :id
-	`
+func (s *SQLStore) UpdateShortcut(shortcut Shortcut) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.lockShortcut(tx, shortcut.ID); err != nil {
+		return err
+	}
 
-	_, err := sqlx.NamedExec(db, query, shortcut)
+	_, err = sqlx.NamedExec(tx, updateShortcut, shortcut)
+	if isUniqueViolation(err) {
+		return ErrCodeTaken
+	}
 	if err != nil {
 		return fmt.Errorf("error updating shortcut: %w", err)
 	}
 
-	return nil
+	if err := recordRevision(tx, shortcut.ID, RevisionUpdated, shortcut.UpdatedBy); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func DeleteShortcut(db *sqlx.DB, shortcut Shortcut) error {
+// DeleteShortcut deletes the shortcut with the given ID. UpdatedBy should be set
+// to the user deleting it, for the revision history.
+func (s *SQLStore) DeleteShortcut(shortcut Shortcut) error {
 	query := `
 		DELETE FROM shortcuts
 		WHERE id = :id
 	`
 
-	_, err := sqlx.NamedExec(db, query, shortcut)
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.lockShortcut(tx, shortcut.ID); err != nil {
+		return err
+	}
+
+	// Snapshot the shortcut before it's gone so that it can be restored later.
+	if err := recordRevision(tx, shortcut.ID, RevisionDeleted, shortcut.UpdatedBy); err != nil {
+		return err
+	}
+
+	_, err = sqlx.NamedExec(tx, query, shortcut)
 	if err != nil {
 		return fmt.Errorf("error deleting shortcut: %w", err)
 	}
 
-	return nil
+	return tx.Commit()
+}
+
+// MarkExpiredShortcuts flags every shortcut whose expiration time has passed,
+// returning the number of shortcuts that were newly marked.
+func (s *SQLStore) MarkExpiredShortcuts(now time.Time) (int64, error) {
+	query := `
+		UPDATE shortcuts
+		SET expired = 1
+		WHERE expired = 0
+		  AND expires_at IS NOT NULL
+		  AND expires_at <= ?
+	`
+
+	res, err := s.db.Exec(query, FormatTime(now))
+	if err != nil {
+		return 0, fmt.Errorf("error marking expired shortcuts: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error getting number of expired shortcuts: %w", err)
+	}
+
+	return n, nil
 }
 
 type TopShortcut struct {
-	ID          int    `db:"id"`
-	Code        string `db:"code"`
-	TotalVisits int64  `db:"total_visits"`
+	ID             int    `db:"id"`
+	Code           string `db:"code"`
+	TotalVisits    int64  `db:"total_visits"`
+	UniqueVisitors int64  `db:"unique_visitors"`
 }
 
 const (
@@ -159,28 +354,58 @@ const (
 	PeriodYear  = "YEAR"
 )
 
-func GetTopShortcuts(db *sqlx.DB, period string) ([]TopShortcut, error) {
-	var validPeriods = map[string]struct{}{
-		PeriodDay:   struct{}{},
-		PeriodWeek:  struct{}{},
-		PeriodMonth: struct{}{},
-		PeriodYear:  struct{}{},
-	}
+var validPeriods = map[string]struct{}{
+	PeriodDay:   {},
+	PeriodWeek:  {},
+	PeriodMonth: {},
+	PeriodYear:  {},
+}
+
+func validatePeriod(period string) error {
 	if _, ok := validPeriods[period]; !ok {
-		return nil, errors.New("invalid period")
+		return errors.New("invalid period")
+	}
+	return nil
+}
+
+// GetTopShortcuts returns the most visited shortcuts in the period. Days that
+// have been rolled up are counted from rollups, and later days from raw visits.
+// Unique visitors are counted per day.
+func (s *SQLStore) GetTopShortcuts(period string) ([]TopShortcut, error) {
+	if err := validatePeriod(period); err != nil {
+		return nil, err
+	}
+
+	from := topPeriodStart(period, time.Now())
+	rawFrom := from
+	watermark, err := s.RolledUpThrough()
+	if err != nil {
+		return nil, err
+	}
+	if watermark.Valid && !watermark.Time.Before(from) {
+		rawFrom = watermark.Time.AddDate(0, 0, 1)
 	}
 
 	query := `
-		select shortcut_id as id, code, count(*) as total_visits
-		from visits
-		join shortcuts on shortcuts.id = visits.shortcut_id
-		where date(timestamp) > DATE_SUB(CURRENT_DATE, INTERVAL 1 ` + period + `)
-		group by shortcut_id
-		order by count(*) desc
+		select shortcut_id as id, code, sum(clicks) as total_visits, sum(uniques) as unique_visitors
+		from (
+			select shortcut_id, clicks, uniques
+			from visit_daily_counts
+			where day >= ? and day < ?
+			union all
+			select shortcut_id, count(*) as clicks, count(distinct nullif(visitor_hash, '')) as uniques
+			from visits
+			where timestamp >= ?
+			group by shortcut_id, date(timestamp)
+		) counts
+		join shortcuts on shortcuts.id = counts.shortcut_id
+		group by shortcut_id, code
+		order by sum(clicks) desc
 		limit 10
 	`
 	shortcuts := make([]TopShortcut, 0)
-	if err := db.Select(&shortcuts, query); err != nil {
+	err = s.db.Select(&shortcuts, query, from.Format(dayLayout), rawFrom.Format(dayLayout), FormatTime(rawFrom))
+	if err != nil {
 		return shortcuts, fmt.Errorf("failed to select top shortcuts: %w", err)
 	}
 
@@ -0,0 +1,301 @@
+package model
+
+import (
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Stats intervals.
+const (
+	IntervalHour = "hour"
+	IntervalDay  = "day"
+	IntervalWeek = "week"
+)
+
+// maxStatsBuckets limits the size of a time series, e.g. hourly stats can be
+// requested for up to about three months.
+const maxStatsBuckets = 2500
+
+// breakdownLimit is the number of entries kept in each breakdown. The rest are
+// summed up as "(other)".
+const breakdownLimit = 20
+
+type VisitStats struct {
+	From        string
+	To          string
+	Interval    string
+	TotalClicks int64
+	// UniqueVisitors are counted per day, since visitor hashes change daily.
+	UniqueVisitors int64
+	Series         []StatsBucket
+	Referers       []BreakdownItem
+	UserAgents     []BreakdownItem
+	Sources        []BreakdownItem
+	// Variants counts clicks per variant, for shortcuts that have variants.
+	Variants []BreakdownItem
+}
+
+type StatsBucket struct {
+	Start          string
+	Clicks         int64
+	UniqueVisitors int64
+}
+
+type BreakdownItem struct {
+	Name   string
+	Clicks int64
+}
+
+// StatsRange is the time range and bucket size for computing stats. To is
+// exclusive.
+type StatsRange struct {
+	From     time.Time
+	To       time.Time
+	Interval string
+}
+
+// bucketStart truncates t to the start of its interval. Weeks start on Monday.
+func bucketStart(t time.Time, interval string) time.Time {
+	t = t.UTC()
+	switch interval {
+	case IntervalHour:
+		return t.Truncate(time.Hour)
+	case IntervalWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+func nextBucket(t time.Time, interval string) time.Time {
+	switch interval {
+	case IntervalHour:
+		return t.Add(time.Hour)
+	case IntervalWeek:
+		return t.AddDate(0, 0, 7)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// Validate checks the range and fills in a default interval. Unless the
+// interval is hourly, the range is widened to whole days, since rolled-up
+// visits are only counted per day.
+func (r *StatsRange) Validate() error {
+	if r.Interval == "" {
+		r.Interval = IntervalDay
+	}
+	switch r.Interval {
+	case IntervalHour, IntervalDay, IntervalWeek:
+	default:
+		return errors.New("interval must be one of hour, day or week")
+	}
+	if !r.From.Before(r.To) {
+		return errors.New("from must be before to")
+	}
+	if r.Interval != IntervalHour {
+		r.From = startOfDay(r.From)
+		if to := startOfDay(r.To); to.Before(r.To) {
+			r.To = to.AddDate(0, 0, 1)
+		}
+	}
+
+	n := 0
+	for t := bucketStart(r.From, r.Interval); t.Before(r.To); t = nextBucket(t, r.Interval) {
+		if n++; n > maxStatsBuckets {
+			return errors.New("too many intervals in range, use a larger interval")
+		}
+	}
+	return nil
+}
+
+// statsCounter accumulates visits into a time series and breakdowns.
+type statsCounter struct {
+	rng      StatsRange
+	total    int64
+	buckets  map[time.Time]int64
+	visitors map[time.Time]map[string]struct{}
+	uniques  map[string]struct{}
+	// Unique visitor counts from rollups, which can't be deduplicated.
+	rolledUpVisitors map[time.Time]int64
+	rolledUpUniques  int64
+	referers         map[string]int64
+	userAgents       map[string]int64
+	sources          map[string]int64
+	variants         map[string]int64
+}
+
+func newStatsCounter(rng StatsRange) *statsCounter {
+	return &statsCounter{
+		rng:              rng,
+		buckets:          make(map[time.Time]int64),
+		visitors:         make(map[time.Time]map[string]struct{}),
+		uniques:          make(map[string]struct{}),
+		rolledUpVisitors: make(map[time.Time]int64),
+		referers:         make(map[string]int64),
+		userAgents:       make(map[string]int64),
+		sources:          make(map[string]int64),
+		variants:         make(map[string]int64),
+	}
+}
+
+func (c *statsCounter) add(v Visit) {
+	t, err := ParseTime(v.Timestamp)
+	if err != nil || t.Before(c.rng.From) || !t.Before(c.rng.To) {
+		return
+	}
+	bucket := bucketStart(t, c.rng.Interval)
+	c.total++
+	c.buckets[bucket]++
+	if v.VisitorHash != "" {
+		if c.visitors[bucket] == nil {
+			c.visitors[bucket] = make(map[string]struct{})
+		}
+		c.visitors[bucket][v.VisitorHash] = struct{}{}
+		c.uniques[v.VisitorHash] = struct{}{}
+	}
+	referer := RefererHost(v.Referer)
+	switch {
+	case v.Referer == "":
+		referer = "(direct)"
+	case referer == "":
+		referer = "(unknown)"
+	}
+	c.referers[referer]++
+	c.userAgents[UserAgentFamily(v.UserAgent)]++
+	c.sources[UTMSource(v.Path)]++
+	if v.Variant != "" {
+		c.variants[v.Variant]++
+	}
+}
+
+// addDaily adds a day of rolled up visits. Daily unique visitor counts can be
+// summed, since visitor hashes change daily anyway.
+func (c *statsCounter) addDaily(dc DailyCount) {
+	day, err := ParseTime(dc.Day)
+	if err != nil {
+		return
+	}
+	bucket := bucketStart(day, c.rng.Interval)
+	c.total += dc.Clicks
+	c.buckets[bucket] += dc.Clicks
+	c.rolledUpVisitors[bucket] += dc.UniqueVisitors
+	c.rolledUpUniques += dc.UniqueVisitors
+	for _, item := range dc.TopReferers {
+		c.referers[item.Name] += item.Clicks
+	}
+	for _, item := range dc.TopUserAgents {
+		c.userAgents[item.Name] += item.Clicks
+	}
+	for _, item := range dc.TopSources {
+		c.sources[item.Name] += item.Clicks
+	}
+	for _, item := range dc.TopVariants {
+		c.variants[item.Name] += item.Clicks
+	}
+}
+
+func (c *statsCounter) stats() VisitStats {
+	stats := VisitStats{
+		From:           c.rng.From.Format(time.RFC3339),
+		To:             c.rng.To.Format(time.RFC3339),
+		Interval:       c.rng.Interval,
+		TotalClicks:    c.total,
+		UniqueVisitors: int64(len(c.uniques)) + c.rolledUpUniques,
+		Series:         make([]StatsBucket, 0),
+		Referers:       breakdown(c.referers),
+		UserAgents:     breakdown(c.userAgents),
+		Sources:        breakdown(c.sources),
+		Variants:       breakdown(c.variants),
+	}
+	for t := bucketStart(c.rng.From, c.rng.Interval); t.Before(c.rng.To); t = nextBucket(t, c.rng.Interval) {
+		stats.Series = append(stats.Series, StatsBucket{
+			Start:          t.Format(time.RFC3339),
+			Clicks:         c.buckets[t],
+			UniqueVisitors: int64(len(c.visitors[t])) + c.rolledUpVisitors[t],
+		})
+	}
+	return stats
+}
+
+// breakdown sorts counts by clicks, keeping the top entries.
+func breakdown(counts map[string]int64) []BreakdownItem {
+	items := make([]BreakdownItem, 0, len(counts))
+	for name, clicks := range counts {
+		items = append(items, BreakdownItem{Name: name, Clicks: clicks})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Clicks != items[j].Clicks {
+			return items[i].Clicks > items[j].Clicks
+		}
+		return items[i].Name < items[j].Name
+	})
+	if len(items) <= breakdownLimit {
+		return items
+	}
+
+	other := BreakdownItem{Name: "(other)"}
+	for _, item := range items[breakdownLimit-1:] {
+		other.Clicks += item.Clicks
+	}
+	return append(items[:breakdownLimit-1], other)
+}
+
+// RefererHost reduces a Referer header to its host name, without "www.". It
+// returns an empty string if the header is empty or has no host name.
+func RefererHost(referer string) string {
+	u, err := url.Parse(referer)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// UTMSource returns the utm_source query parameter of a visit's request path.
+func UTMSource(path string) string {
+	u, err := url.Parse(path)
+	if err != nil {
+		return "(none)"
+	}
+	if source := u.Query().Get("utm_source"); source != "" {
+		return strings.ToLower(source)
+	}
+	return "(none)"
+}
+
+// userAgentFamilies are checked in order, since most browsers also claim to be
+// the browsers they are derived from.
+var userAgentFamilies = []struct {
+	family  string
+	markers []string
+}{
+	{"Bot", []string{"bot", "crawl", "spider", "slurp", "facebookexternalhit", "preview", "curl/", "wget/", "python-requests", "go-http-client"}},
+	{"Edge", []string{"edg/", "edga/", "edgios/"}},
+	{"Opera", []string{"opr/", "opera"}},
+	{"Samsung Internet", []string{"samsungbrowser"}},
+	{"Instagram", []string{"instagram"}},
+	{"Facebook", []string{"fban", "fbav"}},
+	{"Firefox", []string{"firefox/", "fxios/"}},
+	{"Chrome", []string{"chrome/", "crios/"}},
+	{"Safari", []string{"safari/"}},
+}
+
+// UserAgentFamily classifies a User-Agent header into a browser family.
+func UserAgentFamily(userAgent string) string {
+	if userAgent == "" {
+		return "(unknown)"
+	}
+	ua := strings.ToLower(userAgent)
+	for _, f := range userAgentFamilies {
+		for _, marker := range f.markers {
+			if strings.Contains(ua, marker) {
+				return f.family
+			}
+		}
+	}
+	return "Other"
+}
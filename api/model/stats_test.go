@@ -0,0 +1,145 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBucketStart(t *testing.T) {
+	// 2026-03-04 is a Wednesday.
+	at := time.Date(2026, 3, 4, 15, 45, 10, 0, time.UTC)
+	tests := []struct {
+		t        time.Time
+		interval string
+		want     time.Time
+	}{
+		{at, IntervalHour, time.Date(2026, 3, 4, 15, 0, 0, 0, time.UTC)},
+		{at, IntervalDay, time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC)},
+		{at, IntervalWeek, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), IntervalWeek, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 8, 23, 59, 0, 0, time.UTC), IntervalWeek, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC), IntervalWeek, time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)},
+		// Weeks can start in the previous month or year.
+		{time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC), IntervalWeek, time.Date(2025, 12, 29, 0, 0, 0, 0, time.UTC)},
+		// Times are bucketed in UTC.
+		{time.Date(2026, 3, 4, 1, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60)), IntervalDay, time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		if got := bucketStart(test.t, test.interval); !got.Equal(test.want) {
+			t.Errorf("bucketStart(%v, %v) = %v, want %v", test.t, test.interval, got, test.want)
+		}
+	}
+}
+
+func TestStatsCounterSeries(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	rng := StatsRange{From: from, To: from.AddDate(0, 0, 14), Interval: IntervalWeek}
+	if err := rng.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newStatsCounter(rng)
+	for _, day := range []int{0, 1, 7, 8, 8, 14} {
+		c.add(Visit{Timestamp: FormatTime(from.AddDate(0, 0, day).Add(time.Hour))})
+	}
+	stats := c.stats()
+
+	// 2026-03-01 and 2026-03-08 are Sundays, so they end their weeks. The
+	// visit on 2026-03-15 is outside of the range.
+	want := []StatsBucket{
+		{Start: "2026-02-23T00:00:00Z", Clicks: 1},
+		{Start: "2026-03-02T00:00:00Z", Clicks: 2},
+		{Start: "2026-03-09T00:00:00Z", Clicks: 2},
+	}
+	if fmt.Sprint(stats.Series) != fmt.Sprint(want) {
+		t.Errorf("series = %+v, want %+v", stats.Series, want)
+	}
+	if stats.TotalClicks != 5 {
+		t.Errorf("total clicks = %v, want 5", stats.TotalClicks)
+	}
+}
+
+func TestBreakdown(t *testing.T) {
+	counts := make(map[string]int64)
+	for i := 0; i < breakdownLimit+5; i++ {
+		counts[fmt.Sprintf("site%02d.example", i)] = int64(100 - i)
+	}
+	items := breakdown(counts)
+
+	if len(items) != breakdownLimit {
+		t.Fatalf("%v items, want %v", len(items), breakdownLimit)
+	}
+	if items[0].Name != "site00.example" || items[0].Clicks != 100 {
+		t.Errorf("first item = %+v", items[0])
+	}
+	// The last kept entry and the six after it are summed up.
+	other := items[breakdownLimit-1]
+	if other.Name != "(other)" || other.Clicks != 81+80+79+78+77+76 {
+		t.Errorf("last item = %+v", other)
+	}
+
+	if items := breakdown(map[string]int64{"b": 1, "a": 1, "c": 2}); fmt.Sprint(items) != "[{c 2} {a 1} {b 1}]" {
+		t.Errorf("short breakdown = %v", items)
+	}
+}
+
+func TestUserAgentFamily(t *testing.T) {
+	tests := map[string]string{
+		"": "(unknown)",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36":                         "Chrome",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0":           "Edge",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1": "Safari",
+		"Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0":                                                                  "Firefox",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 [FBAN/FBIOS;FBAV/440.0]":   "Facebook",
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)":                                                                "Bot",
+		"curl/8.4.0": "Bot",
+		"Lynx/2.9.0": "Other",
+	}
+	for ua, want := range tests {
+		if got := UserAgentFamily(ua); got != want {
+			t.Errorf("UserAgentFamily(%q) = %q, want %q", ua, got, want)
+		}
+	}
+}
+
+func TestUTMSource(t *testing.T) {
+	tests := map[string]string{
+		"/join":                                "(none)",
+		"/join?utm_source=Newsletter":          "newsletter",
+		"/join?utm_medium=email":               "(none)",
+		"/join/extra?a=1&utm_source=instagram": "instagram",
+		"/join?%zz":                            "(none)",
+	}
+	for path, want := range tests {
+		if got := UTMSource(path); got != want {
+			t.Errorf("UTMSource(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestRefererHost(t *testing.T) {
+	tests := map[string]string{
+		"":                                  "",
+		"https://www.Facebook.com/groups/x": "facebook.com",
+		"https://t.co/abc":                  "t.co",
+		"android-app://com.slack":           "com.slack",
+		"not a url":                         "",
+	}
+	for referer, want := range tests {
+		if got := RefererHost(referer); got != want {
+			t.Errorf("RefererHost(%q) = %q, want %q", referer, got, want)
+		}
+	}
+}
+
+func TestStatsCounterReferers(t *testing.T) {
+	from := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	c := newStatsCounter(StatsRange{From: from, To: from.AddDate(0, 0, 1), Interval: IntervalDay})
+	for _, referer := range []string{"", "https://www.facebook.com/x", "https://facebook.com/y", "not a url"} {
+		c.add(Visit{Timestamp: FormatTime(from), Referer: referer})
+	}
+	if got := fmt.Sprint(c.stats().Referers); got != "[{facebook.com 2} {(direct) 1} {(unknown) 1}]" {
+		t.Errorf("referers = %v", got)
+	}
+}
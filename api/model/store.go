@@ -0,0 +1,67 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// Store is the persistence layer used by the API server. Implementations exist
+// for MySQL (production), SQLite (local development) and memory (tests and
+// throwaway environments).
+//
+// Methods that save a shortcut return ErrCodeTaken if another shortcut already
+// uses its code, and ErrConcurrentChange if it was changed by someone else at
+// the same time.
+type Store interface {
+	GetShortcutByCode(code string) (Shortcut, error)
+	GetShortcutByID(id int) (Shortcut, error)
+	ListShortcuts(opts ListShortcutOptions) ([]Shortcut, int, error)
+	InsertShortcut(shortcut Shortcut) (int64, error)
+	UpdateShortcut(shortcut Shortcut) error
+	DeleteShortcut(shortcut Shortcut) error
+	MarkExpiredShortcuts(now time.Time) (int64, error)
+
+	ListShortcutRevisions(shortcutID int) ([]ShortcutRevision, error)
+	GetShortcutRevision(shortcutID, revision int) (ShortcutRevision, error)
+	ListDeletedShortcuts() ([]ShortcutRevision, error)
+	RevertShortcut(rev ShortcutRevision, userID int) error
+	GetTopShortcuts(period string) ([]TopShortcut, error)
+
+	FindUserByEmail(email string) (User, error)
+	GetUserByID(id int) (User, error)
+	ListUsers() ([]User, error)
+	InsertUser(user User) (int64, error)
+	UpdateUser(user User) error
+	DeleteUser(user User) error
+	UpdateUserLastLoggedIn(user User) error
+
+	InsertVisits(visits []Visit) error
+	ListVisits(shortcutID int, from, to time.Time) ([]Visit, error)
+	FirstVisitTime() (NullTime, error)
+	DeleteVisitsBefore(t time.Time) (int64, error)
+
+	RolledUpThrough() (NullTime, error)
+	SaveDailyCounts(day time.Time, counts []DailyCount) error
+	ListDailyCounts(shortcutID int, from, to time.Time) ([]DailyCount, error)
+
+	RecordMissedCodes(counts map[string]int64, at time.Time) error
+	ListMissedCodes(limit int) ([]MissedCode, error)
+
+	Close() error
+}
+
+// OpenStore returns the Store selected by the DSN:
+//
+//	memory                  in-memory store, nothing is persisted
+//	sqlite:<path>           SQLite database file (use sqlite::memory: for a temporary one)
+//	anything else           MySQL DSN, e.g. user:pass@tcp(host:3306)/db
+func OpenStore(dsn string) (Store, error) {
+	switch {
+	case dsn == "memory":
+		return NewMemoryStore(), nil
+	case strings.HasPrefix(dsn, "sqlite:"):
+		return NewSQLiteStore(strings.TrimPrefix(dsn, "sqlite:"))
+	default:
+		return NewMySQLStore(dsn)
+	}
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// testStores returns an empty memory store and an empty, migrated SQLite
+// store, so that tests can check that both behave the same.
+func testStores(t *testing.T) map[string]Store {
+	t.Helper()
+	return map[string]Store{
+		"memory": NewMemoryStore(),
+		"sqlite": newTestSQLiteStore(t),
+	}
+}
+
+func newTestSQLiteStore(t *testing.T) *SQLStore {
+	t.Helper()
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.MigrateUp(); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
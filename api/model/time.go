@@ -0,0 +1,112 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// timestampLayout matches the format MySQL uses for DATETIME columns. All
+// timestamps are stored in UTC.
+const timestampLayout = "2006-01-02 15:04:05"
+
+var parseLayouts = []string{
+	timestampLayout,
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02",
+}
+
+func now() string {
+	return FormatTime(time.Now())
+}
+
+// FormatTime formats t the way timestamps are stored in the database.
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(timestampLayout)
+}
+
+// ceilSecond rounds t up to a whole second, for use as an exclusive upper
+// bound on stored timestamps, which have second precision.
+func ceilSecond(t time.Time) time.Time {
+	if rounded := t.Truncate(time.Second); !rounded.Equal(t) {
+		return rounded.Add(time.Second)
+	}
+	return t
+}
+
+// ParseTime parses a timestamp as stored by either database, or as an RFC 3339
+// string or date. Timestamps without a zone are taken to be UTC.
+func ParseTime(s string) (time.Time, error) {
+	for _, layout := range parseLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+}
+
+// NullTime is a nullable timestamp. Unlike sql.NullTime it can be scanned from
+// the text MySQL returns for DATETIME columns, and it is encoded in JSON as
+// either null or an RFC 3339 string.
+type NullTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+func NewNullTime(t time.Time) NullTime {
+	return NullTime{Time: t.UTC(), Valid: true}
+}
+
+func (t *NullTime) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*t = NullTime{}
+		return nil
+	case time.Time:
+		*t = NewNullTime(v)
+		return nil
+	case []byte:
+		return t.parse(string(v))
+	case string:
+		return t.parse(v)
+	default:
+		return fmt.Errorf("cannot scan %T into NullTime", value)
+	}
+}
+
+func (t *NullTime) parse(s string) error {
+	parsed, err := ParseTime(s)
+	if err != nil {
+		return err
+	}
+	*t = NewNullTime(parsed)
+	return nil
+}
+
+func (t NullTime) Value() (driver.Value, error) {
+	if !t.Valid {
+		return nil, nil
+	}
+	return FormatTime(t.Time), nil
+}
+
+func (t NullTime) MarshalJSON() ([]byte, error) {
+	if !t.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.Time.Format(time.RFC3339))
+}
+
+func (t *NullTime) UnmarshalJSON(b []byte) error {
+	var s *string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if s == nil || *s == "" {
+		*t = NullTime{}
+		return nil
+	}
+	return t.parse(*s)
+}
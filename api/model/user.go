@@ -17,15 +17,15 @@ type User struct {
 	Admin        bool   `db:"admin"`
 }
 
-func FindUserByEmail(db *sqlx.DB, email string) (User, error) {
+func (s *SQLStore) FindUserByEmail(email string) (User, error) {
 	query := `
-		SELECT id, name, email, created, IFNULL(last_logged_in,"Never") as last_logged_in, active, admin
+		SELECT id, name, email, created, IFNULL(last_logged_in,'Never') as last_logged_in, active, admin
 		FROM users
 		WHERE email = ?
 	`
 
 	var users []User
-	if err := db.Select(&users, query, email); err != nil {
+	if err := s.db.Select(&users, query, email); err != nil {
 		return User{}, fmt.Errorf("failed to select users: %w", err)
 	}
 	if users == nil {
@@ -34,15 +34,32 @@ func FindUserByEmail(db *sqlx.DB, email string) (User, error) {
 	return users[0], nil
 }
 
-func ListUsers(db *sqlx.DB) ([]User, error) {
+func (s *SQLStore) GetUserByID(id int) (User, error) {
 	query := `
-		SELECT id, name, email, created, IFNULL(last_logged_in,"Never") as last_logged_in, active, admin
+		SELECT id, name, email, created, IFNULL(last_logged_in,'Never') as last_logged_in, active, admin
+		FROM users
+		WHERE id = ?
+	`
+
+	var users []User
+	if err := s.db.Select(&users, query, id); err != nil {
+		return User{}, fmt.Errorf("failed to select users: %w", err)
+	}
+	if users == nil {
+		return User{}, nil
+	}
+	return users[0], nil
+}
+
+func (s *SQLStore) ListUsers() ([]User, error) {
+	query := `
+		SELECT id, name, email, created, IFNULL(last_logged_in,'Never') as last_logged_in, active, admin
 		FROM users
 		ORDER by name
 	`
 
 	var users []User
-	if err := db.Select(&users, query); err != nil {
+	if err := s.db.Select(&users, query); err != nil {
 		return nil, fmt.Errorf("failed to select users: %w", err)
 	}
 	if users == nil {
@@ -52,13 +69,13 @@ func ListUsers(db *sqlx.DB) ([]User, error) {
 	return users, nil
 }
 
-func InsertUser(db *sqlx.DB, user User) (int64, error) {
+func (s *SQLStore) InsertUser(user User) (int64, error) {
 	query := `
 		INSERT INTO users (name, email, active, admin)
 		VALUES (:name, :email, :active, :admin) 
 	`
 
-	res, err := sqlx.NamedExec(db, query, user)
+	res, err := sqlx.NamedExec(s.db, query, user)
 	if err != nil {
 		return 0, fmt.Errorf("error inserting user: %w", err)
 	}
@@ -71,12 +88,12 @@ func InsertUser(db *sqlx.DB, user User) (int64, error) {
 	return id, nil
 }
 
-func CreateAndReturnUser(db *sqlx.DB, u User) (User, error) {
-	_, err := InsertUser(db, u)
+func CreateAndReturnUser(store Store, u User) (User, error) {
+	_, err := store.InsertUser(u)
 	if err != nil {
 		return User{}, err
 	}
-	user, err := FindUserByEmail(db, u.Email)
+	user, err := store.FindUserByEmail(u.Email)
 	if err != nil {
 		return User{}, err
 	}
@@ -88,7 +105,7 @@ func CreateAndReturnUser(db *sqlx.DB, u User) (User, error) {
 	return user, nil
 }
 
-func UpdateUser(db *sqlx.DB, user User) error {
+func (s *SQLStore) UpdateUser(user User) error {
 	query := `
 		UPDATE users
 		SET name = :name,
@@ -98,7 +115,7 @@ func UpdateUser(db *sqlx.DB, user User) error {
 		WHERE id = :id
 	`
 
-	_, err := sqlx.NamedExec(db, query, user)
+	_, err := sqlx.NamedExec(s.db, query, user)
 	if err != nil {
 		return fmt.Errorf("error updating user: %w", err)
 	}
@@ -106,13 +123,13 @@ func UpdateUser(db *sqlx.DB, user User) error {
 	return nil
 }
 
-func DeleteUser(db *sqlx.DB, user User) error {
+func (s *SQLStore) DeleteUser(user User) error {
 	query := `
 		DELETE FROM users
 		WHERE id = :id
 	`
 
-	_, err := sqlx.NamedExec(db, query, user)
+	_, err := sqlx.NamedExec(s.db, query, user)
 	if err != nil {
 		return fmt.Errorf("error deleting user: %w", err)
 	}
@@ -120,14 +137,14 @@ func DeleteUser(db *sqlx.DB, user User) error {
 	return nil
 }
 
-func UpdateUserLastLoggedIn(db *sqlx.DB, user User) error {
+func (s *SQLStore) UpdateUserLastLoggedIn(user User) error {
 	query := `
 		UPDATE users
 		SET last_logged_in = CURRENT_TIMESTAMP
 		WHERE id = :id
 	`
 
-	_, err := sqlx.NamedExec(db, query, user)
+	_, err := sqlx.NamedExec(s.db, query, user)
 	if err != nil {
 		return fmt.Errorf("error updating user: %w", err)
 	}
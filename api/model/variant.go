@@ -0,0 +1,51 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+)
+
+// Variant is one of several URLs a shortcut splits its visitors between, in
+// proportion to the variants' weights.
+type Variant struct {
+	Name   string
+	URL    string
+	Weight int
+}
+
+// Variants are stored as JSON in a single column, like RoutingRules.
+type Variants []Variant
+
+func (v *Variants) Scan(value interface{}) error {
+	return scanJSONList(value, (*[]Variant)(v), "Variants")
+}
+
+func (v Variants) Value() (driver.Value, error) {
+	if len(v) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal([]Variant(v))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// MarshalJSON encodes shortcuts without variants as an empty list rather than
+// null.
+func (v Variants) MarshalJSON() ([]byte, error) {
+	if v == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]Variant(v))
+}
+
+// Find returns the variant with the given name.
+func (v Variants) Find(name string) (Variant, bool) {
+	for _, variant := range v {
+		if variant.Name == name {
+			return variant, true
+		}
+	}
+	return Variant{}, false
+}
@@ -2,6 +2,7 @@ package model
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -10,22 +11,75 @@ type Visit struct {
 	ID         int    `db:"id"`
 	Timestamp  string `db:"timestamp"`
 	ShortcutID int    `db:"shortcut_id"`
-	IPAddress  string `db:"ip_address"`
-	Path       string `db:"path"`
-	Referer    string `db:"referer"`
-	UserAgent  string `db:"user_agent"`
+	// VisitorHash identifies a visitor without storing their IP address. It
+	// changes daily, so it can only be used to count unique visitors per day.
+	VisitorHash string `db:"visitor_hash"`
+	Path        string `db:"path"`
+	Referer     string `db:"referer"`
+	UserAgent   string `db:"user_agent"`
+	// Branch is the routing rule the visitor was sent to, BranchDefault if no
+	// rule matched, or empty if the shortcut has no rules.
+	Branch string `db:"branch"`
+	// Variant is the name of the variant the visitor was sent to, if the
+	// shortcut has variants.
+	Variant string `db:"variant"`
+	// Source is how the visitor got the link, such as VisitSourceQR, or empty
+	// if it isn't known.
+	Source string `db:"source"`
 }
 
-func InsertVisit(db *sqlx.DB, visit Visit) error {
+// VisitSourceQR marks visits from scans of a shortcut's QR code.
+const VisitSourceQR = "qr"
+
+// MaxVisitBatchSize is the most visits InsertVisits can insert at once. Each
+// visit binds 9 parameters, and SQLite allows at most 32766 per statement.
+const MaxVisitBatchSize = 32766 / 9
+
+// InsertVisits inserts a batch of visits with a single multi-row INSERT. Visits
+// without a timestamp are recorded at the current time.
+func (s *SQLStore) InsertVisits(visits []Visit) error {
+	if len(visits) == 0 {
+		return nil
+	}
+
 	query := `
-		INSERT INTO visits (shortcut_id, ip_address, path, referer, user_agent)
-		VALUES (:shortcut_id, :ip_address, :path, :referer, :user_agent) 
+		INSERT INTO visits (timestamp, shortcut_id, visitor_hash, path, referer, user_agent, branch, variant, source)
+		VALUES (:timestamp, :shortcut_id, :visitor_hash, :path, :referer, :user_agent, :branch, :variant, :source)
 	`
 
-	_, err := sqlx.NamedExec(db, query, visit)
+	for i := range visits {
+		if visits[i].Timestamp == "" {
+			visits[i].Timestamp = now()
+		}
+	}
+
+	_, err := sqlx.NamedExec(s.db, query, visits)
 	if err != nil {
-		return fmt.Errorf("error inserting visit: %w", err)
+		return fmt.Errorf("error inserting visits: %w", err)
 	}
 
 	return nil
 }
+
+// ListVisits returns a shortcut's visits from the given time range, where to is
+// exclusive. A shortcutID of 0 returns visits for all shortcuts.
+func (s *SQLStore) ListVisits(shortcutID int, from, to time.Time) ([]Visit, error) {
+	query := `
+		SELECT id, timestamp, shortcut_id, visitor_hash, path, referer, user_agent, branch, variant, source
+		FROM visits
+		WHERE timestamp >= ?
+		  AND timestamp < ?
+	`
+	args := []interface{}{FormatTime(from), FormatTime(ceilSecond(to))}
+	if shortcutID != 0 {
+		query += " AND shortcut_id = ?"
+		args = append(args, shortcutID)
+	}
+
+	visits := make([]Visit, 0)
+	if err := s.db.Select(&visits, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to select visits: %w", err)
+	}
+
+	return visits, nil
+}
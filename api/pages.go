@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+//go:embed templates
+var templateFiles embed.FS
+
+// pages are the HTML pages served on the short link domain.
+var pages = template.Must(template.ParseFS(templateFiles, "templates/*.html"))
+
+// shortLinkHost returns how short links are shown on pages, such as dxe.io,
+// from BASE_URL.
+func (s *server) shortLinkHost() string {
+	u, err := url.Parse(s.baseURL)
+	if err != nil || u.Host == "" {
+		return strings.TrimSuffix(s.baseURL, "/")
+	}
+	return u.Host + strings.TrimSuffix(u.Path, "/")
+}
+
+// renderPage writes one of the HTML pages with the given status code.
+func renderPage(w http.ResponseWriter, status int, name string, data interface{}) {
+	var b bytes.Buffer
+	if err := pages.ExecuteTemplate(&b, name, data); err != nil {
+		log.Printf("Failed to render %v: %v", name, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(b.Bytes())
+}
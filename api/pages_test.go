@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dxe/url-shortcuts-go/model"
+)
+
+func TestShortLinkHost(t *testing.T) {
+	tests := map[string]string{
+		"https://dxe.io":          "dxe.io",
+		"https://dxe.io/":         "dxe.io",
+		"http://localhost:8080":   "localhost:8080",
+		"https://example.org/go/": "example.org/go",
+		"go.example.org":          "go.example.org",
+	}
+	for baseURL, want := range tests {
+		s := &server{baseURL: baseURL}
+		if got := s.shortLinkHost(); got != want {
+			t.Errorf("shortLinkHost() for %q = %q, want %q", baseURL, got, want)
+		}
+	}
+}
+
+func TestPagesShowBaseURLHost(t *testing.T) {
+	store := model.NewMemoryStore()
+	s := newTestServer(t, store)
+	s.baseURL = "https://go.example.org"
+	s.fallbackMode = fallbackPage
+	for _, settings := range []model.ShortcutSettings{
+		{Code: "join", URL: "https://example.com/join", Visibility: model.VisibilityPublic},
+		{Code: "members", URL: "https://example.com/members", Visibility: model.VisibilityMembers},
+	} {
+		if _, err := store.InsertShortcut(model.Shortcut{ShortcutSettings: settings}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for path, want := range map[string]string{
+		"/joinn":   "go.example.org/joinn doesn't exist",
+		"/join+":   "go.example.org/join goes to:",
+		"/members": "go.example.org/members is for members only",
+	} {
+		body := serve(s, httptest.NewRequest("GET", path, nil)).Body.String()
+		if !strings.Contains(body, want) {
+			t.Errorf("GET %v doesn't contain %q:\n%v", path, want, body)
+		}
+		if strings.Contains(body, "dxe.io") {
+			t.Errorf("GET %v mentions dxe.io:\n%v", path, body)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dxe/url-shortcuts-go/model"
+)
+
+// previewSuffix after a code, as in dxe.io/join+, shows the shortcut's
+// preview page instead of redirecting. Codes can't contain it.
+const previewSuffix = "+"
+
+const maxTitleLength = 255
+
+// continueParam is added to the Continue links of interstitial pages, so that
+// following them redirects instead of showing the page again.
+const continueParam = "continue"
+
+// previewPage shows where a shortcut goes. Interstitial pages also count down
+// before sending the visitor to Continue.
+type previewPage struct {
+	Host         string
+	Code         string
+	Title        string
+	Destinations []string
+	Owner        string
+	CreatedAt    string
+	Continue     string
+	Countdown    int
+}
+
+func validateTitle(shortcut *model.Shortcut, errs *validationErrors) {
+	shortcut.Title = strings.TrimSpace(shortcut.Title)
+	if len(shortcut.Title) > maxTitleLength {
+		errs.add("Title", "title must be at most %v characters", maxTitleLength)
+	}
+}
+
+// newPreviewPage fills in the details of a shortcut shown on its preview and
+// interstitial pages.
+func (s *server) newPreviewPage(shortcut model.Shortcut) (previewPage, error) {
+	page := previewPage{
+		Host:      s.shortLinkHost(),
+		Code:      shortcut.Code,
+		Title:     shortcut.Title,
+		CreatedAt: shortcut.CreatedAt,
+	}
+	if t, err := model.ParseTime(shortcut.CreatedAt); err == nil {
+		page.CreatedAt = t.Format("January 2, 2006")
+	}
+
+	owner, err := s.store.GetUserByID(shortcut.CreatedBy)
+	if err != nil {
+		return page, err
+	}
+	page.Owner = owner.Name
+
+	return page, nil
+}
+
+// handlePreview shows where a shortcut would send the visitor, without
+// sending them or counting a visit.
+func (s *server) handlePreview(w http.ResponseWriter, r *http.Request, shortcut model.Shortcut, path, rest string) {
+	s.renderPreview(w, r, shortcut, path, rest, 0)
+}
+
+// renderInterstitial shows the preview page of an interstitial shortcut, which
+// continues to the shortcut after a countdown. The visit is only counted once
+// the visitor continues.
+func (s *server) renderInterstitial(w http.ResponseWriter, r *http.Request, shortcut model.Shortcut, path, rest string) {
+	countdown := int(s.interstitialDelay.Seconds())
+	if countdown < 1 {
+		countdown = 1
+	}
+	s.renderPreview(w, r, shortcut, path, rest, countdown)
+}
+
+// renderPreview renders the preview page. If the visitor would be split
+// between variants, all of them are listed, since the variant is only picked
+// once they continue. Continuing goes through the shortcut as usual, skipping
+// the interstitial page.
+func (s *server) renderPreview(w http.ResponseWriter, r *http.Request, shortcut model.Shortcut, path, rest string, countdown int) {
+	page, err := s.newPreviewPage(shortcut)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	target, branch := s.chooseRoute(r, shortcut)
+	urls := []string{target}
+	if variantsApply(shortcut, branch) {
+		urls = nil
+		for _, v := range shortcut.Variants {
+			if v.Weight > 0 {
+				urls = append(urls, v.URL)
+			}
+		}
+	}
+	for _, u := range urls {
+		shortcut.URL = u
+		dest, _, err := targetURL(shortcut, rest, r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		page.Destinations = append(page.Destinations, dest.String())
+	}
+
+	query := r.URL.Query()
+	if shortcut.Interstitial {
+		query.Set(continueParam, "1")
+	}
+	page.Continue = "/" + path
+	if len(query) > 0 {
+		page.Continue += "?" + query.Encode()
+	}
+	page.Countdown = countdown
+
+	w.Header().Set("Cache-Control", "private, no-store")
+	renderPage(w, http.StatusOK, "preview.html", page)
+}
+
+// continuedFromInterstitial reports whether the visitor followed the Continue
+// link of an interstitial page, and removes the tag that says so from the
+// query, so that it isn't passed on.
+func continuedFromInterstitial(query url.Values) bool {
+	if query.Get(continueParam) != "1" {
+		return false
+	}
+	query.Del(continueParam)
+	return true
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/dxe/url-shortcuts-go/model"
+	"github.com/go-chi/chi/v5"
+	"github.com/skip2/go-qrcode"
+	xdraw "golang.org/x/image/draw"
+)
+
+const (
+	defaultQRSize = 512
+	minQRSize     = 64
+	maxQRSize     = 2048
+)
+
+// qrParam is added to the short URLs in QR codes, so that scans can be told
+// apart from typed visits.
+const qrParam = "qr"
+
+// qrLogoScale is the logo's width as a fraction of the QR code's. Error
+// correction level Q or H makes up for the modules it covers.
+const qrLogoScale = 0.2
+
+var qrLevels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+// qrLogo is the image that can be placed in the middle of QR codes.
+type qrLogo struct {
+	image       image.Image
+	data        []byte
+	contentType string
+}
+
+func loadQRLogo(path string) (*qrLogo, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read QR code logo: %w", err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode QR code logo: %w", err)
+	}
+	return &qrLogo{image: img, data: data, contentType: http.DetectContentType(data)}, nil
+}
+
+// qrOptions are how a QR code is rendered, as requested in the query string.
+type qrOptions struct {
+	format     string
+	size       int
+	level      qrcode.RecoveryLevel
+	foreground color.RGBA
+	background color.RGBA
+	logo       bool
+}
+
+func parseQROptions(q url.Values, logo *qrLogo) (qrOptions, error) {
+	opts := qrOptions{
+		format:     "png",
+		size:       defaultQRSize,
+		level:      qrcode.Medium,
+		foreground: color.RGBA{A: 0xff},
+		background: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		logo:       q.Get("logo") == "1" || q.Get("logo") == "true",
+	}
+
+	switch v := strings.ToLower(q.Get("format")); v {
+	case "", "png":
+	case "svg":
+		opts.format = v
+	default:
+		return opts, errors.New("format must be png or svg")
+	}
+
+	if v := q.Get("size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil || size < minQRSize || size > maxQRSize {
+			return opts, fmt.Errorf("size must be between %v and %v", minQRSize, maxQRSize)
+		}
+		opts.size = size
+	}
+
+	if opts.logo {
+		if logo == nil {
+			return opts, errors.New("no logo is configured, set QR_LOGO_PATH")
+		}
+		opts.level = qrcode.Highest
+	}
+	if v := q.Get("ecc"); v != "" {
+		level, ok := qrLevels[strings.ToUpper(v)]
+		if !ok {
+			return opts, errors.New("ecc must be one of L, M, Q or H")
+		}
+		if opts.logo && level < qrcode.High {
+			return opts, errors.New("QR codes with a logo need ecc Q or H")
+		}
+		opts.level = level
+	}
+
+	var err error
+	if v := q.Get("fg"); v != "" {
+		if opts.foreground, err = parseHexColor(v); err != nil {
+			return opts, fmt.Errorf("fg: %w", err)
+		}
+	}
+	if v := q.Get("bg"); v != "" {
+		if opts.background, err = parseHexColor(v); err != nil {
+			return opts, fmt.Errorf("bg: %w", err)
+		}
+	}
+	if opts.foreground == opts.background {
+		return opts, errors.New("fg and bg must be different colors")
+	}
+
+	return opts, nil
+}
+
+// parseHexColor parses colors like "1a2b3c", "#1a2b3c" or "#abc".
+func parseHexColor(s string) (color.RGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	n, err := strconv.ParseUint(s, 16, 32)
+	if len(s) != 6 || err != nil {
+		return color.RGBA{}, errors.New("color must be a hex color such as 000000")
+	}
+	return color.RGBA{R: uint8(n >> 16), G: uint8(n >> 8), B: uint8(n), A: 0xff}, nil
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// shortURL is a shortcut's full short link.
+func (s *server) shortURL(code string) string {
+	return strings.TrimSuffix(s.baseURL, "/") + "/" + code
+}
+
+// getShortcutQR renders a QR code for a shortcut's short link, tagged with
+// qrParam.
+func (s *server) getShortcutQR(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	opts, err := parseQROptions(r.URL.Query(), s.qrLogo)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	shortcut, err := s.store.GetShortcutByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if shortcut.ID == 0 {
+		http.Error(w, "shortcut not found", http.StatusNotFound)
+		return
+	}
+
+	code, err := qrcode.New(s.shortURL(shortcut.Code)+"?"+qrParam+"=1", opts.level)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	code.ForegroundColor, code.BackgroundColor = opts.foreground, opts.background
+
+	var logo *qrLogo
+	if opts.logo {
+		logo = s.qrLogo
+	}
+
+	var body []byte
+	var contentType string
+	switch opts.format {
+	case "svg":
+		body, contentType = renderQRSVG(code, opts, logo), "image/svg+xml"
+	default:
+		if body, err = renderQRPNG(code, opts, logo); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		contentType = "image/png"
+	}
+
+	filename := strings.ReplaceAll(shortcut.Code, "/", "-") + "." + opts.format
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", filename))
+	w.Write(body)
+}
+
+// qrLogoRect returns where the logo goes in a QR code of the given width,
+// centered and at most qrLogoScale of the width in either direction.
+func qrLogoRect(width float64, logo image.Rectangle) (x, y, w, h float64) {
+	side := width * qrLogoScale
+	w, h = side, side
+	if logo.Dx() > logo.Dy() {
+		h = side * float64(logo.Dy()) / float64(logo.Dx())
+	} else {
+		w = side * float64(logo.Dx()) / float64(logo.Dy())
+	}
+	return (width - w) / 2, (width - h) / 2, w, h
+}
+
+func renderQRPNG(code *qrcode.QRCode, opts qrOptions, logo *qrLogo) ([]byte, error) {
+	img := code.Image(opts.size)
+	canvas := image.NewRGBA(img.Bounds())
+	draw.Draw(canvas, canvas.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	if logo != nil {
+		x, y, w, h := qrLogoRect(float64(canvas.Bounds().Dx()), logo.image.Bounds())
+		target := image.Rect(int(x), int(y), int(x+w), int(y+h))
+		// Clear the modules behind the logo, so that transparent logos
+		// stay legible.
+		draw.Draw(canvas, target.Inset(-int(w)/10), image.NewUniform(opts.background), image.Point{}, draw.Src)
+		xdraw.CatmullRom.Scale(canvas, target, logo.image, logo.image.Bounds(), draw.Over, nil)
+	}
+
+	var b bytes.Buffer
+	if err := png.Encode(&b, canvas); err != nil {
+		return nil, fmt.Errorf("failed to encode QR code: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+// renderQRSVG draws each row's runs of dark modules as a single path, with the
+// logo embedded as a data URI.
+func renderQRSVG(code *qrcode.QRCode, opts qrOptions, logo *qrLogo) []byte {
+	bitmap := code.Bitmap()
+	n := len(bitmap)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, opts.size, opts.size, n, n)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="%v"/>`, n, n, hexColor(opts.background))
+	fmt.Fprintf(&b, `<path fill="%v" d="`, hexColor(opts.foreground))
+	for y, row := range bitmap {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&b, "M%d %dh%dv1h-%dz", start, y, x-start, x-start)
+		}
+	}
+	b.WriteString(`"/>`)
+
+	if logo != nil {
+		x, y, w, h := qrLogoRect(float64(n), logo.image.Bounds())
+		pad := w / 10
+		fmt.Fprintf(&b, `<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="%v"/>`, x-pad, y-pad, w+2*pad, h+2*pad, hexColor(opts.background))
+		fmt.Fprintf(&b, `<image x="%.2f" y="%.2f" width="%.2f" height="%.2f" xlink:href="data:%v;base64,%v"/>`, x, y, w, h, logo.contentType, base64.StdEncoding.EncodeToString(logo.data))
+	}
+
+	b.WriteString(`</svg>`)
+	return b.Bytes()
+}
+
+// visitSource returns how a visitor got to a shortcut, and removes the tag
+// that says so from the query, so that it isn't passed on.
+func visitSource(query url.Values) string {
+	if query.Get(qrParam) == "1" {
+		query.Del(qrParam)
+		return model.VisitSourceQR
+	}
+	return ""
+}
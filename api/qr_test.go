@@ -0,0 +1,65 @@
+package main
+
+import (
+	"image/color"
+	"net/url"
+	"testing"
+
+	"github.com/skip2/go-qrcode"
+)
+
+func TestParseQROptions(t *testing.T) {
+	logo := &qrLogo{}
+	tests := []struct {
+		query   string
+		logo    *qrLogo
+		want    qrOptions
+		wantErr bool
+	}{
+		{query: "", want: qrOptions{format: "png", size: defaultQRSize, level: qrcode.Medium,
+			foreground: color.RGBA{A: 0xff}, background: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}}},
+		{query: "format=SVG&size=256&ecc=q&fg=%23ff0000&bg=00f", want: qrOptions{format: "svg", size: 256, level: qrcode.High,
+			foreground: color.RGBA{R: 0xff, A: 0xff}, background: color.RGBA{B: 0xff, A: 0xff}}},
+		{query: "logo=1", logo: logo, want: qrOptions{format: "png", size: defaultQRSize, level: qrcode.Highest,
+			foreground: color.RGBA{A: 0xff}, background: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, logo: true}},
+		{query: "format=gif", wantErr: true},
+		{query: "size=63", wantErr: true},
+		{query: "size=2049", wantErr: true},
+		{query: "size=big", wantErr: true},
+		{query: "ecc=X", wantErr: true},
+		{query: "logo=1", wantErr: true},
+		{query: "logo=1&ecc=M", logo: logo, wantErr: true},
+		{query: "fg=nothex", wantErr: true},
+		{query: "fg=fff", wantErr: true},
+	}
+	for _, tt := range tests {
+		q, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := parseQROptions(q, tt.logo)
+		switch {
+		case tt.wantErr && err == nil:
+			t.Errorf("parseQROptions(%q) succeeded, want an error", tt.query)
+		case !tt.wantErr && err != nil:
+			t.Errorf("parseQROptions(%q) failed: %v", tt.query, err)
+		case !tt.wantErr && got != tt.want:
+			t.Errorf("parseQROptions(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestVisitSource(t *testing.T) {
+	q := url.Values{"qr": {"1"}, "a": {"b"}}
+	if source := visitSource(q); source != "qr" {
+		t.Errorf("source = %q, want qr", source)
+	}
+	if q.Encode() != "a=b" {
+		t.Errorf("query after removing the tag = %q", q.Encode())
+	}
+
+	q = url.Values{"qr": {"code"}}
+	if source := visitSource(q); source != "" || q.Get("qr") != "code" {
+		t.Errorf("other qr values are kept, got source %q and query %q", source, q.Encode())
+	}
+}
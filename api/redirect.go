@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dxe/url-shortcuts-go/model"
+)
+
+// redirectStatuses are the statuses a shortcut can redirect with. 302 is the
+// default, since shortcuts are often repointed and browsers remember permanent
+// redirects.
+var redirectStatuses = []int{
+	http.StatusMovedPermanently,
+	http.StatusFound,
+	http.StatusTemporaryRedirect,
+	http.StatusPermanentRedirect,
+}
+
+func validateRedirectStatus(shortcut *model.Shortcut, errs *validationErrors) {
+	if shortcut.RedirectStatus == 0 {
+		shortcut.RedirectStatus = http.StatusFound
+		return
+	}
+	for _, status := range redirectStatuses {
+		if shortcut.RedirectStatus == status {
+			return
+		}
+	}
+	errs.add("RedirectStatus", "redirect status must be one of 301, 302, 307 or 308")
+}
+
+// redirectStatus returns the status to redirect to a shortcut with.
+func redirectStatus(shortcut model.Shortcut) int {
+	if shortcut.RedirectStatus == 0 {
+		return http.StatusFound
+	}
+	return shortcut.RedirectStatus
+}
+
+func isPermanentRedirect(status int) bool {
+	return status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect
+}
+
+// setRedirectCacheControl tells browsers and proxies how long they may reuse a
+// redirect without asking again. Permanent redirects are cached for at most
+// maxAge, and never past the shortcut's expiration, so that they can still be
+// repointed. Temporary redirects aren't cached at all, so that every visit is
+// counted and changes take effect right away. Shortcuts with variants are
+// never cached either, since each visit picks a variant, nor are protected
+// shortcuts, whose URLs only some visitors may see. Shortcuts with routing
+// rules or UTM tagging redirect each visitor differently, since utm_source
+// comes from the Referer, so only browsers may cache them.
+func setRedirectCacheControl(w http.ResponseWriter, shortcut model.Shortcut, maxAge time.Duration, now time.Time) {
+	protected := shortcut.Visibility != "" && shortcut.Visibility != model.VisibilityPublic
+	if !isPermanentRedirect(redirectStatus(shortcut)) || len(shortcut.Variants) > 0 || protected {
+		w.Header().Set("Cache-Control", "private, no-store")
+		return
+	}
+	if shortcut.ExpiresAt.Valid {
+		if untilExpired := shortcut.ExpiresAt.Time.Sub(now); untilExpired < maxAge {
+			maxAge = untilExpired
+		}
+	}
+	var vary []string
+	if len(shortcut.Rules) > 0 {
+		vary = append(vary, "User-Agent", "Accept-Language")
+	}
+	if shortcut.UTMMode != model.UTMDisabled {
+		vary = append(vary, "Referer")
+	}
+	scope := "public"
+	if len(vary) > 0 {
+		scope = "private"
+		w.Header().Add("Vary", strings.Join(vary, ", "))
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("%v, max-age=%d", scope, int(maxAge.Seconds())))
+}
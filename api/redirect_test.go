@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dxe/url-shortcuts-go/model"
+)
+
+func TestSetRedirectCacheControl(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	permanent := model.Shortcut{ShortcutSettings: model.ShortcutSettings{RedirectStatus: http.StatusMovedPermanently, UTMMode: model.UTMDisabled}}
+
+	withUTM := permanent
+	withUTM.UTMMode = model.UTMInherit
+	withRules := permanent
+	withRules.Rules = model.RoutingRules{{Kind: model.RulePlatform, Value: "ios", URL: "https://example.com/"}}
+	expiring := permanent
+	expiring.ExpiresAt = model.NullTime{Time: now.Add(10 * time.Minute), Valid: true}
+	withVariants := permanent
+	withVariants.Variants = model.Variants{{Name: "a", URL: "https://example.com/", Weight: 1}}
+	protected := permanent
+	protected.Visibility = model.VisibilityMembers
+
+	tests := []struct {
+		name         string
+		shortcut     model.Shortcut
+		cacheControl string
+		vary         string
+	}{
+		{"temporary", model.Shortcut{ShortcutSettings: model.ShortcutSettings{RedirectStatus: http.StatusFound}}, "private, no-store", ""},
+		{"permanent", permanent, "public, max-age=3600", ""},
+		{"utm tagged", withUTM, "private, max-age=3600", "Referer"},
+		{"rules", withRules, "private, max-age=3600", "User-Agent, Accept-Language"},
+		{"expiring", expiring, "public, max-age=600", ""},
+		{"variants", withVariants, "private, no-store", ""},
+		{"protected", protected, "private, no-store", ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		setRedirectCacheControl(w, tt.shortcut, time.Hour, now)
+		if got := w.Header().Get("Cache-Control"); got != tt.cacheControl {
+			t.Errorf("%v: Cache-Control = %q, want %q", tt.name, got, tt.cacheControl)
+		}
+		if got := w.Header().Get("Vary"); got != tt.vary {
+			t.Errorf("%v: Vary = %q, want %q", tt.name, got, tt.vary)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/dxe/url-shortcuts-go/model"
+)
+
+// rollupGracePeriod is how long to wait after midnight before rolling up the
+// previous day, so that visits still queued in the recorder are included.
+const rollupGracePeriod = time.Hour
+
+// rollupVisits periodically rolls up finished days of visits into daily
+// counts, until ctx is cancelled. If retentionDays is positive, raw visits
+// older than that are deleted, but only once their day has been rolled up.
+func (s *server) rollupVisits(ctx context.Context, interval time.Duration, retentionDays int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := time.Now(); ; {
+		s.rollupVisitsAt(now, retentionDays)
+
+		select {
+		case <-ctx.Done():
+			return
+		case now = <-ticker.C:
+		}
+	}
+}
+
+func (s *server) rollupVisitsAt(now time.Time, retentionDays int) {
+	today := now.UTC().Add(-rollupGracePeriod).Truncate(24 * time.Hour)
+	n, err := model.RollupVisitsThrough(s.store, today.AddDate(0, 0, -1))
+	if err != nil {
+		log.Printf("Failed to roll up visits: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("Rolled up %v days of visits", n)
+	}
+
+	if retentionDays <= 0 {
+		return
+	}
+	watermark, err := s.store.RolledUpThrough()
+	if err != nil {
+		log.Printf("Failed to get rollup state: %v", err)
+		return
+	}
+	if !watermark.Valid {
+		return
+	}
+	cutoff := today.AddDate(0, 0, -retentionDays)
+	if rolledUp := watermark.Time.AddDate(0, 0, 1); rolledUp.Before(cutoff) {
+		cutoff = rolledUp
+	}
+	deleted, err := s.store.DeleteVisitsBefore(cutoff)
+	if err != nil {
+		log.Printf("Failed to delete old visits: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("Deleted %v visits older than %v", deleted, cutoff.Format("2006-01-02"))
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/dxe/url-shortcuts-go/model"
+	"github.com/oschwald/maxminddb-golang"
+	"golang.org/x/text/language"
+)
+
+const maxRoutingRules = 20
+
+// Platforms that platform rules can match, as detected by platformOf.
+var platforms = []string{"ios", "android", "windows", "macos", "linux"}
+
+var countryPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// geoIP looks up the countries of IP addresses in a MaxMind database, such as
+// GeoLite2 Country. A nil *geoIP finds no countries.
+type geoIP struct {
+	db *maxminddb.Reader
+}
+
+func openGeoIP(path string) (*geoIP, error) {
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database: %w", err)
+	}
+	return &geoIP{db: db}, nil
+}
+
+// Country returns the ISO code of the country remoteAddr is in, or an empty
+// string if it isn't known.
+func (g *geoIP) Country(remoteAddr string) string {
+	if g == nil {
+		return ""
+	}
+	host := remoteAddr
+	if h, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		host = h
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ""
+	}
+
+	var record struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+	if err := g.db.Lookup(ip, &record); err != nil {
+		log.Printf("Failed to look up country of %v: %v", ip, err)
+		return ""
+	}
+	return record.Country.ISOCode
+}
+
+func (g *geoIP) Close() error {
+	if g == nil {
+		return nil
+	}
+	return g.db.Close()
+}
+
+// platformOf guesses the operating system a User-Agent header comes from.
+func platformOf(userAgent string) string {
+	switch {
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"), strings.Contains(userAgent, "iPod"):
+		return "ios"
+	case strings.Contains(userAgent, "Android"):
+		return "android"
+	case strings.Contains(userAgent, "Windows"):
+		return "windows"
+	case strings.Contains(userAgent, "Macintosh"):
+		return "macos"
+	case strings.Contains(userAgent, "Linux"):
+		return "linux"
+	default:
+		return ""
+	}
+}
+
+// preferredLanguage returns the language an Accept-Language header prefers
+// most, or language.Und if there is none.
+func preferredLanguage(acceptLanguage string) language.Tag {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil {
+		return language.Und
+	}
+	for _, tag := range tags {
+		if tag != language.Und {
+			return tag
+		}
+	}
+	return language.Und
+}
+
+// languageMatches reports whether the preferred language is the rule's
+// language. A rule without a region, such as "es", matches all regions.
+func languageMatches(rule, preferred language.Tag) bool {
+	ruleBase, _, ruleRegion := rule.Raw()
+	base, _, region := preferred.Raw()
+	if ruleBase != base {
+		return false
+	}
+	return ruleRegion == (language.Region{}) || ruleRegion == region
+}
+
+// routingVisitor holds what routing rules are matched against. The country is
+// only looked up once a country rule is reached.
+type routingVisitor struct {
+	r     *http.Request
+	geoIP *geoIP
+
+	country       string
+	countryLooked bool
+}
+
+func (v *routingVisitor) matches(rule model.RoutingRule) bool {
+	switch rule.Kind {
+	case model.RulePlatform:
+		return platformOf(v.r.Header.Get("User-Agent")) == rule.Value
+	case model.RuleLanguage:
+		tag, err := language.Parse(rule.Value)
+		return err == nil && languageMatches(tag, preferredLanguage(v.r.Header.Get("Accept-Language")))
+	case model.RuleCountry:
+		if !v.countryLooked {
+			v.country, v.countryLooked = v.geoIP.Country(v.r.RemoteAddr), true
+		}
+		return v.country != "" && v.country == rule.Value
+	default:
+		return false
+	}
+}
+
+// chooseRoute returns the URL to send a visitor to, which is that of the first
+// rule they match or else the shortcut's URL, and the branch to record on the
+// visit.
+func (s *server) chooseRoute(r *http.Request, shortcut model.Shortcut) (string, string) {
+	if len(shortcut.Rules) == 0 {
+		return shortcut.URL, ""
+	}
+
+	v := routingVisitor{r: r, geoIP: s.geoIP}
+	for _, rule := range shortcut.Rules {
+		if v.matches(rule) {
+			return rule.URL, rule.Branch()
+		}
+	}
+	return shortcut.URL, model.BranchDefault
+}
+
+// validateRules checks a shortcut's routing rules and normalizes their values.
+func validateRules(shortcut *model.Shortcut, errs *validationErrors) {
+	if len(shortcut.Rules) > maxRoutingRules {
+		errs.add("Rules", "shortcuts can have at most %v routing rules", maxRoutingRules)
+		return
+	}
+
+	for i := range shortcut.Rules {
+		rule := &shortcut.Rules[i]
+		field := fmt.Sprintf("Rules[%d]", i)
+
+		rule.Value = strings.TrimSpace(rule.Value)
+		switch rule.Kind {
+		case model.RulePlatform:
+			rule.Value = strings.ToLower(rule.Value)
+			if !containsString(platforms, rule.Value) {
+				errs.add(field+".Value", "platform must be one of %v", strings.Join(platforms, ", "))
+			}
+		case model.RuleLanguage:
+			tag, err := language.Parse(rule.Value)
+			if err != nil || tag == language.Und {
+				errs.add(field+".Value", "%q is not a valid language tag", rule.Value)
+			} else {
+				rule.Value = tag.String()
+			}
+		case model.RuleCountry:
+			rule.Value = strings.ToUpper(rule.Value)
+			if !countryPattern.MatchString(rule.Value) {
+				errs.add(field+".Value", "country must be a two-letter ISO country code")
+			}
+		default:
+			errs.add(field+".Kind", "rule kind must be one of %v, %v or %v", model.RulePlatform, model.RuleLanguage, model.RuleCountry)
+		}
+
+		validateShortcutURL(field+".URL", rule.URL, shortcut.IsPrefix, errs)
+	}
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dxe/url-shortcuts-go/model"
+)
+
+func TestChooseRoute(t *testing.T) {
+	shortcut := model.Shortcut{
+		ShortcutSettings: model.ShortcutSettings{
+			URL: "https://example.com/default",
+			Rules: model.RoutingRules{
+				{Kind: model.RulePlatform, Value: "ios", URL: "https://example.com/ios"},
+				{Kind: model.RuleLanguage, Value: "es", URL: "https://example.com/es"},
+				{Kind: model.RuleLanguage, Value: "pt-BR", URL: "https://example.com/pt-br"},
+				{Kind: model.RuleCountry, Value: "DE", URL: "https://example.com/de"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		userAgent      string
+		acceptLanguage string
+		wantURL        string
+		wantBranch     string
+	}{
+		{"no match", "Mozilla/5.0 (X11; Linux x86_64)", "en-US", "https://example.com/default", model.BranchDefault},
+		{"platform", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)", "es", "https://example.com/ios", "platform:ios"},
+		{"language in any region", "Mozilla/5.0 (Windows NT 10.0)", "es-MX,en;q=0.8", "https://example.com/es", "language:es"},
+		{"only the preferred language", "Mozilla/5.0 (Windows NT 10.0)", "en,es;q=0.8", "https://example.com/default", model.BranchDefault},
+		{"language with region", "Mozilla/5.0 (Windows NT 10.0)", "pt-BR", "https://example.com/pt-br", "language:pt-BR"},
+		{"language in another region", "Mozilla/5.0 (Windows NT 10.0)", "pt-PT", "https://example.com/default", model.BranchDefault},
+	}
+	// Without a GeoIP database, country rules never match.
+	s := &server{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/code", nil)
+			r.Header.Set("User-Agent", tt.userAgent)
+			r.Header.Set("Accept-Language", tt.acceptLanguage)
+
+			url, branch := s.chooseRoute(r, shortcut)
+			if url != tt.wantURL || branch != tt.wantBranch {
+				t.Errorf("chooseRoute = %v, %v, want %v, %v", url, branch, tt.wantURL, tt.wantBranch)
+			}
+		})
+	}
+}
+
+func TestChooseRouteWithoutRules(t *testing.T) {
+	s := &server{}
+	url, branch := s.chooseRoute(httptest.NewRequest("GET", "/code", nil), model.Shortcut{ShortcutSettings: model.ShortcutSettings{URL: "https://example.com/"}})
+	if url != "https://example.com/" || branch != "" {
+		t.Errorf("chooseRoute = %v, %q, want the shortcut's URL and no branch", url, branch)
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	shortcut := model.Shortcut{
+		ShortcutSettings: model.ShortcutSettings{
+			URL: "https://example.com/",
+			Rules: model.RoutingRules{
+				{Kind: model.RulePlatform, Value: " iOS ", URL: "https://example.com/ios"},
+				{Kind: model.RuleLanguage, Value: "pt-br", URL: "https://example.com/pt"},
+				{Kind: model.RuleCountry, Value: "de", URL: "https://example.com/de"},
+			},
+		},
+	}
+	var errs validationErrors
+	validateRules(&shortcut, &errs)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	for i, want := range []string{"ios", "pt-BR", "DE"} {
+		if got := shortcut.Rules[i].Value; got != want {
+			t.Errorf("rule %v value = %q, want %q", i, got, want)
+		}
+	}
+
+	shortcut.Rules = model.RoutingRules{
+		{Kind: model.RulePlatform, Value: "beos", URL: "https://example.com/"},
+		{Kind: "browser", Value: "firefox", URL: "https://example.com/"},
+		{Kind: model.RuleCountry, Value: "Germany", URL: "ftp://example.com/"},
+	}
+	errs = nil
+	validateRules(&shortcut, &errs)
+	var fields []string
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+	want := []string{"Rules[0].Value", "Rules[1].Kind", "Rules[2].Value", "Rules[2].URL"}
+	if len(fields) != len(want) {
+		t.Fatalf("errors on %v, want %v", fields, want)
+	}
+	for i := range want {
+		if fields[i] != want[i] {
+			t.Errorf("errors on %v, want %v", fields, want)
+			break
+		}
+	}
+}
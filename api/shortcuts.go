@@ -2,10 +2,11 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
-	"strings"
+	"time"
 
 	"github.com/dxe/url-shortcuts-go/model"
 	"github.com/go-chi/chi/v5"
@@ -20,7 +21,7 @@ func (s *server) getShortcuts(w http.ResponseWriter, r *http.Request) {
 		Page:  page,
 	}
 
-	shortcuts, total, err := model.ListShortcuts(s.db, opts)
+	shortcuts, total, err := s.store.ListShortcuts(opts)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -31,27 +32,66 @@ func (s *server) getShortcuts(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// shortcutRequest is a shortcut as sent by clients. Password sets a new
+// password for a password protected shortcut; if it is empty, the shortcut
+// keeps its current one.
+type shortcutRequest struct {
+	model.Shortcut
+	Password string
+}
+
 func (s *server) createShortcut(w http.ResponseWriter, r *http.Request) {
 	user := mustGetUserFromCtx(r.Context())
 
-	// TODO: validate input data
-	var shortcut model.Shortcut
-	err := json.NewDecoder(r.Body).Decode(&shortcut)
+	var req shortcutRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	shortcut := req.Shortcut
+
+	// Leaving the code empty asks for a random one.
+	generateCode := model.NormalizeCode(shortcut.Code) == ""
+	if generateCode {
+		if shortcut.Code, err = s.codes.Generate(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if errs := validateShortcut(&shortcut, req.Password); len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
 
 	shortcut.CreatedBy, shortcut.UpdatedBy = user.ID, user.ID
 
-	id, err := model.InsertShortcut(s.db, shortcut)
+	id, err := s.store.InsertShortcut(shortcut)
+	for attempt := 1; generateCode && errors.Is(err, model.ErrCodeTaken) && attempt < maxCodeAttempts; attempt++ {
+		if shortcut.Code, err = s.codes.Generate(); err != nil {
+			break
+		}
+		id, err = s.store.InsertShortcut(shortcut)
+	}
+	if generateCode && errors.Is(err, model.ErrCodeTaken) {
+		http.Error(w, "failed to generate an unused code, try a longer code length", http.StatusServiceUnavailable)
+		return
+	}
+	if errors.Is(err, model.ErrCodeTaken) {
+		s.writeCodeTaken(w, shortcut.Code)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	s.invalidateShortcuts(shortcut.Code)
+	s.codeIndex.Invalidate()
 
 	writeJSON(w, map[string]interface{}{
-		"id": id,
+		"id":   id,
+		"code": shortcut.Code,
 	})
 }
 
@@ -65,36 +105,187 @@ func (s *server) updateShortcut(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var shortcut model.Shortcut
-	err = json.NewDecoder(r.Body).Decode(&shortcut)
+	var req shortcutRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	shortcut := req.Shortcut
 
-	reservedKeywords := []string{"api", "shortcut", "healthz", "auth"}
-	for _, k := range reservedKeywords {
-		if strings.HasPrefix(shortcut.Code, k) {
-			http.Error(w, fmt.Sprintf("shortcut code beginning with '%v' is not allowed", k), http.StatusBadRequest)
-			return
-		}
+	existing, err := s.store.GetShortcutByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if existing.ID == 0 {
+		http.Error(w, "shortcut not found", http.StatusNotFound)
+		return
+	}
+
+	// Clients never see the password hash, so keep it unless a new password
+	// is given.
+	shortcut.PasswordHash = existing.PasswordHash
+	if errs := validateShortcut(&shortcut, req.Password); len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
 	}
 
 	shortcut.ID = id
 	shortcut.UpdatedBy = user.ID
 
-	err = model.UpdateShortcut(s.db, shortcut)
+	err = s.store.UpdateShortcut(shortcut)
+	if errors.Is(err, model.ErrCodeTaken) {
+		s.writeCodeTaken(w, shortcut.Code)
+		return
+	}
+	if errors.Is(err, model.ErrConcurrentChange) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	s.invalidateShortcuts(existing.Code, shortcut.Code)
+	s.codeIndex.Invalidate()
 
 	writeJSON(w, map[string]interface{}{
 		"id": id,
 	})
 }
 
+// writeCodeTaken responds with 409 Conflict and the shortcut that already uses
+// the code, so that the user can go and edit it instead.
+func (s *server) writeCodeTaken(w http.ResponseWriter, code string) {
+	owner, err := s.store.GetShortcutByCode(code)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": validationErrors{
+			{Field: "Code", Message: fmt.Sprintf("code '%v' is already taken", code)},
+		},
+		"shortcut": map[string]interface{}{
+			"ID":  owner.ID,
+			"URL": owner.URL,
+		},
+	})
+}
+
+// getCodeAvailability reports whether a code can be used for a new shortcut,
+// and suggests free variants if it can't.
+func (s *server) getCodeAvailability(w http.ResponseWriter, r *http.Request) {
+	code := model.NormalizeCode(r.URL.Query().Get("code"))
+
+	var errs validationErrors
+	validateCode(code, &errs)
+	if len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	owner, err := s.store.GetShortcutByCode(code)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if owner.ID == 0 {
+		writeJSON(w, map[string]interface{}{
+			"code":      code,
+			"available": true,
+		})
+		return
+	}
+
+	suggestions, err := s.suggestCodes(code, maxCodeSuggestions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"code":      code,
+		"available": false,
+		"shortcut": map[string]interface{}{
+			"ID":  owner.ID,
+			"URL": owner.URL,
+		},
+		"suggestions": suggestions,
+	})
+}
+
+const maxCodeSuggestions = 5
+
+// suggestCodes returns up to n unused variants of a code, such as
+// "volunteer-2024" or "volunteer-2".
+func (s *server) suggestCodes(code string, n int) ([]string, error) {
+	candidates := []string{fmt.Sprintf("%v-%v", code, time.Now().Year())}
+	for i := 2; i < 2+2*n; i++ {
+		candidates = append(candidates, fmt.Sprintf("%v-%v", code, i))
+	}
+
+	suggestions := make([]string, 0, n)
+	for _, candidate := range candidates {
+		if len(suggestions) == n {
+			break
+		}
+		var errs validationErrors
+		if validateCode(candidate, &errs); len(errs) > 0 {
+			continue
+		}
+		owner, err := s.store.GetShortcutByCode(candidate)
+		if err != nil {
+			return nil, err
+		}
+		if owner.ID == 0 {
+			suggestions = append(suggestions, candidate)
+		}
+	}
+	return suggestions, nil
+}
+
 func (s *server) deleteShortcut(w http.ResponseWriter, r *http.Request) {
+	user := mustGetUserFromCtx(r.Context())
+
+	idParam := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	existing, err := s.store.GetShortcutByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if existing.ID == 0 {
+		http.Error(w, "shortcut not found", http.StatusNotFound)
+		return
+	}
+
+	err = s.store.DeleteShortcut(model.Shortcut{ID: id, UpdatedBy: user.ID})
+	if errors.Is(err, model.ErrConcurrentChange) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.invalidateShortcuts(existing.Code)
+	s.codeIndex.Invalidate()
+
+	writeJSON(w, map[string]interface{}{
+		"id": id,
+	})
+}
+
+func (s *server) getShortcutHistory(w http.ResponseWriter, r *http.Request) {
 	idParam := chi.URLParam(r, "id")
 	id, err := strconv.Atoi(idParam)
 	if err != nil {
@@ -102,31 +293,108 @@ func (s *server) deleteShortcut(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = model.DeleteShortcut(s.db, model.Shortcut{ID: id})
+	revisions, err := s.store.ListShortcutRevisions(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(revisions) == 0 {
+		http.Error(w, "shortcut not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"revisions": revisions,
+	})
+}
+
+// revertShortcut restores a shortcut to a previous revision. This also brings
+// back shortcuts that have been deleted.
+func (s *server) revertShortcut(w http.ResponseWriter, r *http.Request) {
+	user := mustGetUserFromCtx(r.Context())
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rev, err := strconv.Atoi(chi.URLParam(r, "rev"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	revision, err := s.store.GetShortcutRevision(id, rev)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if revision.ID == 0 {
+		http.Error(w, "revision not found", http.StatusNotFound)
+		return
+	}
+
+	// Old revisions may predate the current validation rules, or have been
+	// imported without them.
+	restored := model.Shortcut{ShortcutSettings: revision.ShortcutSettings}
+	if errs := validateShortcut(&restored, ""); len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+	revision.ShortcutSettings = restored.ShortcutSettings
+
+	existing, err := s.store.GetShortcutByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = s.store.RevertShortcut(revision, user.ID)
+	if errors.Is(err, model.ErrCodeTaken) {
+		s.writeCodeTaken(w, revision.Code)
+		return
+	}
+	if errors.Is(err, model.ErrConcurrentChange) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	s.invalidateShortcuts(existing.Code, revision.Code)
+	s.codeIndex.Invalidate()
 
 	writeJSON(w, map[string]interface{}{
 		"id": id,
 	})
 }
 
+func (s *server) getDeletedShortcuts(w http.ResponseWriter, r *http.Request) {
+	revisions, err := s.store.ListDeletedShortcuts()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"shortcuts": revisions,
+	})
+}
+
 func (s *server) getTopShortcuts(w http.ResponseWriter, r *http.Request) {
-	day, err := model.GetTopShortcuts(s.db, model.PeriodDay)
+	day, err := s.store.GetTopShortcuts(model.PeriodDay)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	week, err := model.GetTopShortcuts(s.db, model.PeriodWeek)
+	week, err := s.store.GetTopShortcuts(model.PeriodWeek)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	month, err := model.GetTopShortcuts(s.db, model.PeriodMonth)
+	month, err := s.store.GetTopShortcuts(model.PeriodMonth)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
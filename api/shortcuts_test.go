@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/dxe/url-shortcuts-go/model"
+)
+
+func createShortcut(t *testing.T, s *server, cookie *http.Cookie, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest("POST", "/api/shortcuts/", strings.NewReader(body))
+	r.AddCookie(cookie)
+	return serve(s, r)
+}
+
+func TestCreateShortcutNormalizesCode(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t, store)
+			cookie := loginCookie(t, s)
+
+			w := createShortcut(t, s, cookie, `{"Code": " Join/ ", "URL": "https://example.com/join"}`)
+			if w.Code != http.StatusOK {
+				t.Fatalf("create = %v %v", w.Code, w.Body)
+			}
+			var created struct{ Code string }
+			if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+				t.Fatal(err)
+			}
+			if created.Code != "join" {
+				t.Errorf("code = %q, want %q", created.Code, "join")
+			}
+
+			w = serve(s, httptest.NewRequest("GET", "/JOIN/", nil))
+			if w.Code != http.StatusFound || !strings.HasPrefix(w.Header().Get("Location"), "https://example.com/join?") {
+				t.Errorf("redirect = %v %v", w.Code, w.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestCreateShortcutCodeTaken(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t, store)
+			cookie := loginCookie(t, s)
+
+			if w := createShortcut(t, s, cookie, `{"Code": "join", "URL": "https://example.com/a"}`); w.Code != http.StatusOK {
+				t.Fatalf("create = %v %v", w.Code, w.Body)
+			}
+			w := createShortcut(t, s, cookie, `{"Code": "JOIN", "URL": "https://example.com/b"}`)
+			if w.Code != http.StatusConflict {
+				t.Fatalf("create with taken code = %v %v", w.Code, w.Body)
+			}
+
+			var body struct {
+				Errors   []fieldError
+				Shortcut struct{ URL string }
+			}
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if len(body.Errors) != 1 || body.Errors[0].Field != "Code" {
+				t.Errorf("errors = %+v", body.Errors)
+			}
+			if body.Shortcut.URL != "https://example.com/a" {
+				t.Errorf("existing shortcut URL = %q", body.Shortcut.URL)
+			}
+		})
+	}
+}
+
+func TestDeleteMissingShortcut(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t, store)
+			cookie := loginCookie(t, s)
+
+			r := httptest.NewRequest("DELETE", "/api/shortcuts/"+strconv.Itoa(42), nil)
+			r.AddCookie(cookie)
+			if w := serve(s, r); w.Code != http.StatusNotFound {
+				t.Errorf("delete = %v %v", w.Code, w.Body)
+			}
+		})
+	}
+}
+
+func TestRevertValidatesRevision(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t, store)
+			cookie := loginCookie(t, s)
+
+			// Shortcuts saved before validation existed, or imported, can
+			// have codes and URLs that are no longer allowed.
+			id, err := store.InsertShortcut(model.Shortcut{ShortcutSettings: model.ShortcutSettings{Code: "Old Code", URL: "javascript:alert(1)"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			valid := model.ShortcutSettings{Code: "join", URL: "https://example.com/join", Visibility: model.VisibilityPublic}
+			if err := store.UpdateShortcut(model.Shortcut{ID: int(id), ShortcutSettings: valid}); err != nil {
+				t.Fatal(err)
+			}
+
+			r := httptest.NewRequest("POST", "/api/shortcuts/"+strconv.Itoa(int(id))+"/revert/1", nil)
+			r.AddCookie(cookie)
+			w := serve(s, r)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("revert to invalid revision = %v %v", w.Code, w.Body)
+			}
+			if shortcut, _ := store.GetShortcutByID(int(id)); shortcut.Code != "join" {
+				t.Errorf("code after failed revert = %q", shortcut.Code)
+			}
+
+			r = httptest.NewRequest("POST", "/api/shortcuts/"+strconv.Itoa(int(id))+"/revert/2", nil)
+			r.AddCookie(cookie)
+			if w := serve(s, r); w.Code != http.StatusOK {
+				t.Errorf("revert to valid revision = %v %v", w.Code, w.Body)
+			}
+		})
+	}
+}
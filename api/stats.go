@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dxe/url-shortcuts-go/model"
+	"github.com/go-chi/chi/v5"
+)
+
+// parseStatsRange reads the from, to and interval query parameters. The range
+// defaults to the last seven days.
+func parseStatsRange(r *http.Request) (model.StatsRange, error) {
+	q := r.URL.Query()
+	rng := model.StatsRange{
+		To:       time.Now().UTC(),
+		Interval: q.Get("interval"),
+	}
+	rng.From = rng.To.AddDate(0, 0, -7)
+
+	if v := q.Get("from"); v != "" {
+		t, err := model.ParseTime(v)
+		if err != nil {
+			return rng, err
+		}
+		rng.From = t
+	}
+	if v := q.Get("to"); v != "" {
+		t, err := model.ParseTime(v)
+		if err != nil {
+			return rng, err
+		}
+		rng.To = t
+	}
+
+	return rng, rng.Validate()
+}
+
+func (s *server) getShortcutStats(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rng, err := parseStatsRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	shortcut, err := s.store.GetShortcutByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if shortcut.ID == 0 {
+		http.Error(w, "shortcut not found", http.StatusNotFound)
+		return
+	}
+
+	stats, err := model.ShortcutStats(s.store, id, rng)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"shortcut": shortcut,
+		"stats":    stats,
+	})
+}
+
+// getVariantStats compares clicks per variant of a shortcut over the same
+// range as getShortcutStats.
+func (s *server) getVariantStats(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rng, err := parseStatsRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	shortcut, err := s.store.GetShortcutByID(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if shortcut.ID == 0 {
+		http.Error(w, "shortcut not found", http.StatusNotFound)
+		return
+	}
+
+	stats, err := model.ShortcutStats(s.store, id, rng)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"from":     stats.From,
+		"to":       stats.To,
+		"variants": compareVariants(shortcut.Variants, stats.Variants),
+	})
+}
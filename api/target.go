@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dxe/url-shortcuts-go/model"
+)
+
+// Shortcut URLs can contain placeholders that are filled in at redirect time:
+//
+//	{path}        the rest of the requested path after a prefix shortcut's code
+//	{1}, {2}, ... a single segment of the rest of the path
+//	{q}           the segments of the rest of the path, separated by spaces
+//	{query.name}  the request's query parameter name
+//
+// Values are escaped for the part of the URL they are in.
+var placeholderPattern = regexp.MustCompile(`\{([^{}]*)\}`)
+
+var queryParamPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// placeholder is a parsed placeholder name.
+type placeholder struct {
+	// fromPath is set for placeholders filled from the rest of the path,
+	// which only prefix shortcuts have.
+	fromPath bool
+	segment  int
+	param    string
+}
+
+func parsePlaceholder(name string) (placeholder, error) {
+	switch {
+	case name == "path" || name == "q":
+		return placeholder{fromPath: true}, nil
+	case strings.HasPrefix(name, "query."):
+		param := strings.TrimPrefix(name, "query.")
+		if !queryParamPattern.MatchString(param) {
+			return placeholder{}, fmt.Errorf("{%v} is not a valid query parameter name", name)
+		}
+		return placeholder{param: param}, nil
+	default:
+		n, err := strconv.Atoi(name)
+		if err != nil || n < 1 || strconv.Itoa(n) != name {
+			return placeholder{}, fmt.Errorf("unknown placeholder {%v}", name)
+		}
+		return placeholder{fromPath: true, segment: n}, nil
+	}
+}
+
+// usesPathPlaceholder reports whether the URL has any placeholders filled from
+// the rest of the path. If not, prefix shortcuts append the rest of the path.
+func usesPathPlaceholder(template string) bool {
+	for _, m := range placeholderPattern.FindAllStringSubmatch(template, -1) {
+		if p, err := parsePlaceholder(m[1]); err == nil && p.fromPath {
+			return true
+		}
+	}
+	return false
+}
+
+// validateTemplate checks the placeholders in a shortcut URL. Placeholders
+// filled from the rest of the path can only be used if isPrefix is set.
+func validateTemplate(field, template string, isPrefix bool, errs *validationErrors) {
+	matches := placeholderPattern.FindAllStringSubmatchIndex(template, -1)
+	if len(matches) == 0 {
+		return
+	}
+
+	// Placeholders in the host would let anyone use the shortcut to redirect
+	// to any site.
+	pathStart := len(template)
+	if i := strings.Index(template, "://"); i >= 0 {
+		if j := strings.IndexAny(template[i+3:], "/?#"); j >= 0 {
+			pathStart = i + 3 + j
+		}
+	}
+
+	for _, m := range matches {
+		name := template[m[2]:m[3]]
+		p, err := parsePlaceholder(name)
+		switch {
+		case err != nil:
+			errs.add(field, "%v", err)
+		case m[0] < pathStart:
+			errs.add(field, "{%v} can't be used in the scheme or host of the URL", name)
+		case p.fromPath && !isPrefix:
+			errs.add(field, "{%v} can only be used in prefix shortcuts", name)
+		}
+	}
+}
+
+// targetURL returns the URL a shortcut redirects to, with its placeholders
+// filled in. For prefix shortcuts, rest is the part of the requested path
+// after the code; it is appended to the URL's path unless the URL has
+// placeholders for it. The names of query parameters used by placeholders are
+// also returned, so that they aren't passed on a second time.
+func targetURL(shortcut model.Shortcut, rest string, query url.Values) (*url.URL, []string, error) {
+	expanded, used := expandTemplate(shortcut.URL, rest, query)
+
+	target, err := url.Parse(expanded)
+	if err != nil {
+		return nil, nil, err
+	}
+	if shortcut.IsPrefix && rest != "" && !usesPathPlaceholder(shortcut.URL) {
+		escaped := strings.TrimSuffix(target.EscapedPath(), "/") + "/" + escapePath(rest)
+		if target.Path, err = url.PathUnescape(escaped); err != nil {
+			return nil, nil, err
+		}
+		target.RawPath = escaped
+	}
+	return target, used, nil
+}
+
+func expandTemplate(template, rest string, query url.Values) (string, []string) {
+	var segments []string
+	if rest != "" {
+		segments = strings.Split(rest, "/")
+	}
+	// Everything after the path is escaped as a query parameter.
+	queryStart := strings.IndexAny(template, "?#")
+	if queryStart < 0 {
+		queryStart = len(template)
+	}
+
+	var b strings.Builder
+	var used []string
+	last := 0
+	for _, m := range placeholderPattern.FindAllStringSubmatchIndex(template, -1) {
+		b.WriteString(template[last:m[0]])
+		last = m[1]
+
+		p, err := parsePlaceholder(template[m[2]:m[3]])
+		if err != nil {
+			// Saved before validation existed, so leave it as it is.
+			b.WriteString(template[m[0]:m[1]])
+			continue
+		}
+
+		name := template[m[2]:m[3]]
+		var value string
+		switch {
+		case name == "path":
+			value = rest
+		case name == "q":
+			value = strings.Join(segments, " ")
+		case p.param != "":
+			value = query.Get(p.param)
+			used = append(used, p.param)
+		case p.segment <= len(segments):
+			value = segments[p.segment-1]
+		}
+
+		switch {
+		case m[0] >= queryStart:
+			b.WriteString(url.QueryEscape(value))
+		case name == "path":
+			b.WriteString(escapePath(value))
+		default:
+			b.WriteString(url.PathEscape(value))
+		}
+	}
+	b.WriteString(template[last:])
+
+	return b.String(), used
+}
+
+// escapePath escapes each segment of a slash-separated path.
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/dxe/url-shortcuts-go/model"
+)
+
+func TestTargetURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		isPrefix bool
+		rest     string
+		query    string
+		want     string
+		wantUsed []string
+	}{
+		{name: "plain", url: "https://example.com/a?b=c", want: "https://example.com/a?b=c"},
+		{name: "prefix without rest", url: "https://drive.google.com/drive", isPrefix: true, want: "https://drive.google.com/drive"},
+		{name: "prefix", url: "https://drive.google.com/drive", isPrefix: true, rest: "abc/def", want: "https://drive.google.com/drive/abc/def"},
+		{name: "prefix with trailing slash", url: "https://example.com/docs/", isPrefix: true, rest: "x", want: "https://example.com/docs/x"},
+		{name: "prefix escapes rest", url: "https://example.com/docs", isPrefix: true, rest: "a b/c?d", want: "https://example.com/docs/a%20b/c%3Fd"},
+		{name: "path", url: "https://example.com/{path}/edit", isPrefix: true, rest: "a/b", want: "https://example.com/a/b/edit"},
+		{name: "segments", url: "https://github.com/{1}/issues/{2}", isPrefix: true, rest: "dxe/42", want: "https://github.com/dxe/issues/42"},
+		{name: "missing segment", url: "https://github.com/{1}/issues/{2}", isPrefix: true, rest: "dxe", want: "https://github.com/dxe/issues/"},
+		{name: "segment escaped", url: "https://example.com/{1}", isPrefix: true, rest: "a b", want: "https://example.com/a%20b"},
+		{name: "q in query", url: "https://example.com/search?q={q}", isPrefix: true, rest: "cute/cats", want: "https://example.com/search?q=cute+cats"},
+		{name: "path in query", url: "https://example.com/?p={path}", isPrefix: true, rest: "a/b&c", want: "https://example.com/?p=a%2Fb%26c"},
+		{name: "query param", url: "https://example.com/item/{query.id}", query: "id=4 2&x=y", want: "https://example.com/item/4%202", wantUsed: []string{"id"}},
+		{name: "missing query param", url: "https://example.com/?id={query.id}", want: "https://example.com/?id=", wantUsed: []string{"id"}},
+		{name: "unknown placeholder kept", url: "https://example.com/{nope}", want: "https://example.com/%7Bnope%7D"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			shortcut := model.Shortcut{ShortcutSettings: model.ShortcutSettings{URL: tt.url, IsPrefix: tt.isPrefix}}
+			got, used, err := targetURL(shortcut, tt.rest, query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("targetURL = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(used, tt.wantUsed) {
+				t.Errorf("used = %v, want %v", used, tt.wantUsed)
+			}
+		})
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	tests := []struct {
+		url      string
+		isPrefix bool
+		valid    bool
+	}{
+		{"https://example.com/{path}", true, true},
+		{"https://example.com/{path}", false, false},
+		{"https://example.com/{query.id}", false, true},
+		{"https://example.com/{query.a b}", false, false},
+		{"https://{1}.example.com/", true, false},
+		{"https://example.com/{0}", true, false},
+		{"https://example.com/{01}", true, false},
+		{"https://example.com/{nope}", true, false},
+	}
+	for _, tt := range tests {
+		var errs validationErrors
+		validateShortcutURL("URL", tt.url, tt.isPrefix, &errs)
+		if valid := len(errs) == 0; valid != tt.valid {
+			t.Errorf("validateShortcutURL(%q, isPrefix %v) = %v, want valid %v", tt.url, tt.isPrefix, errs, tt.valid)
+		}
+	}
+}
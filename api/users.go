@@ -10,7 +10,7 @@ import (
 )
 
 func (s *server) getUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := model.ListUsers(s.db)
+	users, err := s.store.ListUsers()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -21,7 +21,6 @@ func (s *server) getUsers(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *server) createUser(w http.ResponseWriter, r *http.Request) {
-	// TODO: validate input data
 	var user model.User
 	err := json.NewDecoder(r.Body).Decode(&user)
 	if err != nil {
@@ -29,7 +28,12 @@ func (s *server) createUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := model.InsertUser(s.db, user)
+	if errs := validateUser(user); len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	id, err := s.store.InsertUser(user)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -48,7 +52,6 @@ func (s *server) updateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// TODO: validate input data
 	var user model.User
 	err = json.NewDecoder(r.Body).Decode(&user)
 	if err != nil {
@@ -56,9 +59,14 @@ func (s *server) updateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if errs := validateUser(user); len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
 	user.ID = id
 
-	err = model.UpdateUser(s.db, user)
+	err = s.store.UpdateUser(user)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -77,7 +85,7 @@ func (s *server) deleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = model.DeleteUser(s.db, model.User{ID: id})
+	err = s.store.DeleteUser(model.User{ID: id})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -93,7 +101,7 @@ func (s *server) getCurrentUser(w http.ResponseWriter, r *http.Request) {
 	// Confirm that the user is still active in the database, otherwise a user might
 	// still have access after being removed
 	if s.prod {
-		userFromDB, err := model.FindUserByEmail(s.db, user.Email)
+		userFromDB, err := s.store.FindUserByEmail(user.Email)
 		if err != nil {
 			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			return
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/dxe/url-shortcuts-go/model"
+)
+
+// utmDefaults are the UTM parameters redirects are tagged with, unless a
+// shortcut overrides them.
+type utmDefaults struct {
+	Source string
+	Medium string
+	// CampaignPrefix is prepended to the shortcut's code to get the campaign.
+	CampaignPrefix string
+}
+
+// buildQueryString merges the target URL's and the request's query parameters,
+// overwriting values from earlier args with later args, and tags the result
+// according to the shortcut's UTM mode. utm_source and utm_medium that are
+// already set are kept; utm_campaign always identifies the shortcut.
+func (d utmDefaults) buildQueryString(r *http.Request, shortcut model.Shortcut, args ...url.Values) string {
+	output := make(url.Values, 0)
+	for _, u := range args {
+		for k, v := range u {
+			output.Set(k, v[0])
+		}
+	}
+	if shortcut.UTMMode == model.UTMDisabled {
+		return output.Encode()
+	}
+
+	source, medium, campaign := d.Source, d.Medium, d.CampaignPrefix+shortcut.Code
+	if host := model.RefererHost(r.Header.Get("Referer")); host != "" {
+		source = host
+	}
+	if shortcut.UTMMode == model.UTMCustom {
+		source = firstNonEmpty(shortcut.UTMSource, source)
+		medium = firstNonEmpty(shortcut.UTMMedium, medium)
+		campaign = firstNonEmpty(shortcut.UTMCampaign, campaign)
+	}
+
+	if output.Get("utm_source") == "" && source != "" {
+		output.Set("utm_source", source)
+	}
+	if output.Get("utm_medium") == "" && medium != "" {
+		output.Set("utm_medium", medium)
+	}
+	output.Set("utm_campaign", campaign)
+	return output.Encode()
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func validateUTM(shortcut *model.Shortcut, errs *validationErrors) {
+	switch shortcut.UTMMode {
+	case "":
+		shortcut.UTMMode = model.UTMInherit
+	case model.UTMInherit, model.UTMCustom, model.UTMDisabled:
+	default:
+		errs.add("UTMMode", "UTM mode must be one of %v, %v or %v", model.UTMInherit, model.UTMCustom, model.UTMDisabled)
+		return
+	}
+
+	fields := []struct{ name, value string }{
+		{"UTMSource", shortcut.UTMSource},
+		{"UTMMedium", shortcut.UTMMedium},
+		{"UTMCampaign", shortcut.UTMCampaign},
+	}
+	for _, f := range fields {
+		switch {
+		case f.value != "" && shortcut.UTMMode != model.UTMCustom:
+			errs.add(f.name, "UTM values can only be set in %v mode", model.UTMCustom)
+		case len(f.value) > 255:
+			errs.add(f.name, "%v must be at most 255 characters", f.name)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dxe/url-shortcuts-go/model"
+)
+
+func TestBuildQueryStringSource(t *testing.T) {
+	d := utmDefaults{Source: "dxe-io", Medium: "shortlink", CampaignPrefix: "dxe-io-"}
+	shortcut := model.Shortcut{ShortcutSettings: model.ShortcutSettings{Code: "join", UTMMode: model.UTMInherit}}
+
+	// utm_source is the referer's host as counted in stats.
+	tests := map[string]string{
+		"":                                  "dxe-io",
+		"https://www.Facebook.com/groups/x": "facebook.com",
+		"https://t.co/abc":                  "t.co",
+		"not a url":                         "dxe-io",
+	}
+	for referer, want := range tests {
+		r := httptest.NewRequest("GET", "/join", nil)
+		r.Header.Set("Referer", referer)
+		query, err := url.ParseQuery(d.buildQueryString(r, shortcut))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := query.Get("utm_source"); got != want {
+			t.Errorf("utm_source for referer %q = %q, want %q", referer, got, want)
+		}
+		if got := query.Get("utm_campaign"); got != "dxe-io-join" {
+			t.Errorf("utm_campaign = %q", got)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dxe/url-shortcuts-go/model"
+)
+
+const maxCodeLength = 64
+
+// codePattern allows codes made of several parts separated by slashes, such as
+// "docs/meetings", so that prefix shortcuts can be nested.
+var codePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+(/[A-Za-z0-9_-]+)*$`)
+
+// reservedCodes collide with routes served by this server or the frontend, so
+// they can't be used as shortcut codes.
+var reservedCodes = map[string]struct{}{
+	"api":       {},
+	"auth":      {},
+	"healthz":   {},
+	"shortcut":  {},
+	"shortcuts": {},
+}
+
+// fieldError describes a problem with a single field of a request payload.
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type validationErrors []fieldError
+
+func (v *validationErrors) add(field, format string, args ...interface{}) {
+	*v = append(*v, fieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// writeValidationErrors responds with 400 Bad Request and the field errors as
+// JSON, so that the frontend can show them next to the fields.
+func writeValidationErrors(w http.ResponseWriter, errs validationErrors) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": errs,
+	})
+}
+
+func validateCode(code string, errs *validationErrors) {
+	switch {
+	case code == "":
+		errs.add("Code", "code must not be blank")
+	case len(code) > maxCodeLength:
+		errs.add("Code", "code must be at most %v characters", maxCodeLength)
+	case !codePattern.MatchString(code):
+		errs.add("Code", "code may only contain letters, numbers, '-' and '_', and '/' between parts")
+	default:
+		first := strings.SplitN(code, "/", 2)[0]
+		if _, ok := reservedCodes[strings.ToLower(first)]; ok {
+			errs.add("Code", "code '%v' is reserved", first)
+		}
+	}
+}
+
+func validateTargetURL(field, target string, errs *validationErrors) {
+	u, err := url.Parse(target)
+	switch {
+	case target == "":
+		errs.add(field, "URL must not be blank")
+	case err != nil:
+		errs.add(field, "URL is invalid: %v", err)
+	case u.Scheme != "http" && u.Scheme != "https":
+		errs.add(field, "URL must begin with http:// or https://")
+	case u.Host == "":
+		errs.add(field, "URL must include a host name")
+	}
+}
+
+// validateShortcut checks a shortcut before it is saved. It also normalizes its
+// code, sets whether it has already expired, and hashes the new password, if
+// any.
+func validateShortcut(shortcut *model.Shortcut, password string) validationErrors {
+	shortcut.Code = model.NormalizeCode(shortcut.Code)
+
+	var errs validationErrors
+	validateCode(shortcut.Code, &errs)
+	validateShortcutURL("URL", shortcut.URL, shortcut.IsPrefix, &errs)
+	validateUTM(shortcut, &errs)
+	validateRedirectStatus(shortcut, &errs)
+	validateRules(shortcut, &errs)
+	validateVariants(shortcut, &errs)
+	validateVisibility(shortcut, password, &errs)
+	validateTitle(shortcut, &errs)
+
+	if shortcut.StartsAt.Valid && shortcut.ExpiresAt.Valid && !shortcut.ExpiresAt.Time.After(shortcut.StartsAt.Time) {
+		errs.add("ExpiresAt", "shortcut must expire after it starts")
+	}
+	shortcut.Expired = shortcut.StateAt(time.Now()) == model.ShortcutExpired
+
+	return errs
+}
+
+// validateShortcutURL checks a URL a shortcut redirects to, including its
+// placeholders.
+func validateShortcutURL(field, target string, isPrefix bool, errs *validationErrors) {
+	// Placeholders are checked separately, so they don't count as invalid
+	// characters here.
+	validateTargetURL(field, placeholderPattern.ReplaceAllString(target, "x"), errs)
+	validateTemplate(field, target, isPrefix, errs)
+}
+
+func validateUser(user model.User) validationErrors {
+	var errs validationErrors
+	if strings.TrimSpace(user.Name) == "" {
+		errs.add("Name", "name must not be blank")
+	}
+
+	if user.Email == "" {
+		errs.add("Email", "email must not be blank")
+	} else if addr, err := mail.ParseAddress(user.Email); err != nil || addr.Address != user.Email {
+		errs.add("Email", "email must be a valid email address")
+	}
+
+	return errs
+}
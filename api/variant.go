@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/dxe/url-shortcuts-go/model"
+)
+
+const (
+	maxVariants      = 10
+	maxVariantWeight = 1000
+)
+
+// variantCookieMaxAge is how long sticky variants stick.
+const variantCookieMaxAge = 90 * 24 * time.Hour
+
+var variantNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// variantCookie is the name of the cookie that remembers which of a
+// shortcut's variants a visitor was sent to.
+func variantCookie(shortcut model.Shortcut) string {
+	return fmt.Sprintf("variant_%d", shortcut.ID)
+}
+
+// chooseVariant picks one of a shortcut's variants at random, in proportion to
+// their weights. For sticky variants, a visitor who has been sent to a
+// variant that still exists is sent there again.
+func chooseVariant(w http.ResponseWriter, r *http.Request, shortcut model.Shortcut) (model.Variant, error) {
+	if shortcut.StickyVariants {
+		if c, err := r.Cookie(variantCookie(shortcut)); err == nil {
+			if v, ok := shortcut.Variants.Find(c.Value); ok && v.Weight > 0 {
+				return v, nil
+			}
+		}
+	}
+
+	v, err := pickVariant(shortcut.Variants)
+	if err != nil {
+		return model.Variant{}, err
+	}
+
+	if shortcut.StickyVariants {
+		http.SetCookie(w, &http.Cookie{
+			Name:     variantCookie(shortcut),
+			Value:    v.Name,
+			MaxAge:   int(variantCookieMaxAge.Seconds()),
+			SameSite: http.SameSiteLaxMode,
+			HttpOnly: true,
+			Path:     "/",
+		})
+	}
+	return v, nil
+}
+
+// variantsApply reports whether a visitor sent to branch by the routing rules
+// is split between the shortcut's variants, which happens if they matched no
+// rule.
+func variantsApply(shortcut model.Shortcut, branch string) bool {
+	return len(shortcut.Variants) > 0 && (branch == "" || branch == model.BranchDefault)
+}
+
+func pickVariant(variants model.Variants) (model.Variant, error) {
+	total := 0
+	for _, v := range variants {
+		total += v.Weight
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(total)))
+	if err != nil {
+		return model.Variant{}, fmt.Errorf("failed to pick variant: %w", err)
+	}
+
+	remaining := int(n.Int64())
+	for _, v := range variants {
+		if remaining < v.Weight {
+			return v, nil
+		}
+		remaining -= v.Weight
+	}
+	return variants[len(variants)-1], nil
+}
+
+func validateVariants(shortcut *model.Shortcut, errs *validationErrors) {
+	if len(shortcut.Variants) == 0 {
+		if shortcut.StickyVariants {
+			errs.add("StickyVariants", "sticky variants require variants")
+		}
+		return
+	}
+	if len(shortcut.Variants) < 2 || len(shortcut.Variants) > maxVariants {
+		errs.add("Variants", "shortcuts must have between 2 and %v variants", maxVariants)
+		return
+	}
+
+	names := make(map[string]bool)
+	total := 0
+	for i, v := range shortcut.Variants {
+		field := fmt.Sprintf("Variants[%d]", i)
+		switch {
+		case !variantNamePattern.MatchString(v.Name):
+			errs.add(field+".Name", "variant names must be 1 to 32 letters, numbers, '-' or '_'")
+		case names[v.Name]:
+			errs.add(field+".Name", "variant name %q is used more than once", v.Name)
+		}
+		names[v.Name] = true
+
+		if v.Weight < 0 || v.Weight > maxVariantWeight {
+			errs.add(field+".Weight", "weight must be between 0 and %v", maxVariantWeight)
+		}
+		total += v.Weight
+
+		validateShortcutURL(field+".URL", v.URL, shortcut.IsPrefix, errs)
+	}
+	if total <= 0 {
+		errs.add("Variants", "at least one variant must have a weight above 0")
+	}
+}
+
+// variantStats compares a variant's clicks with its share of visitors.
+type variantStats struct {
+	Name   string
+	URL    string
+	Weight int
+	Clicks int64
+	// Share is the variant's share of clicks, and ExpectedShare its share of
+	// the total weight.
+	Share         float64
+	ExpectedShare float64
+}
+
+// compareVariants lists clicks for each of a shortcut's variants, followed by
+// any variants that have since been removed but still had clicks.
+func compareVariants(variants model.Variants, clicks []model.BreakdownItem) []variantStats {
+	counts := make(map[string]int64)
+	var totalClicks int64
+	for _, item := range clicks {
+		counts[item.Name] = item.Clicks
+		totalClicks += item.Clicks
+	}
+	totalWeight := 0
+	for _, v := range variants {
+		totalWeight += v.Weight
+	}
+
+	stats := make([]variantStats, 0, len(variants))
+	for _, v := range variants {
+		vs := variantStats{Name: v.Name, URL: v.URL, Weight: v.Weight, Clicks: counts[v.Name]}
+		if totalWeight > 0 {
+			vs.ExpectedShare = float64(v.Weight) / float64(totalWeight)
+		}
+		stats = append(stats, vs)
+		delete(counts, v.Name)
+	}
+	for _, item := range clicks {
+		if _, ok := counts[item.Name]; ok {
+			stats = append(stats, variantStats{Name: item.Name, Clicks: item.Clicks})
+		}
+	}
+	if totalClicks > 0 {
+		for i := range stats {
+			stats[i].Share = float64(stats[i].Clicks) / float64(totalClicks)
+		}
+	}
+	return stats
+}
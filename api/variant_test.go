@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dxe/url-shortcuts-go/model"
+)
+
+func TestPickVariant(t *testing.T) {
+	variants := model.Variants{
+		{Name: "a", URL: "https://example.com/a", Weight: 1},
+		{Name: "off", URL: "https://example.com/off", Weight: 0},
+		{Name: "b", URL: "https://example.com/b", Weight: 3},
+	}
+
+	counts := make(map[string]int)
+	const n = 4000
+	for i := 0; i < n; i++ {
+		v, err := pickVariant(variants)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[v.Name]++
+	}
+	if counts["off"] != 0 {
+		t.Errorf("variant with no weight picked %v times", counts["off"])
+	}
+	// a should get a quarter of the picks; this allows for more than 8
+	// standard deviations either way.
+	if counts["a"] < 780 || counts["a"] > 1220 {
+		t.Errorf("a picked %v times out of %v, want about %v", counts["a"], n, n/4)
+	}
+}
+
+func TestChooseVariantSticky(t *testing.T) {
+	shortcut := model.Shortcut{
+		ID: 7,
+		ShortcutSettings: model.ShortcutSettings{
+			Variants: model.Variants{
+				{Name: "a", URL: "https://example.com/a", Weight: 1},
+				{Name: "b", URL: "https://example.com/b", Weight: 1},
+			},
+			StickyVariants: true,
+		},
+	}
+
+	w := httptest.NewRecorder()
+	first, err := chooseVariant(w, httptest.NewRequest("GET", "/code", nil), shortcut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "variant_7" || cookies[0].Value != first.Name {
+		t.Fatalf("cookies = %v, want variant_7=%v", cookies, first.Name)
+	}
+
+	for i := 0; i < 20; i++ {
+		r := httptest.NewRequest("GET", "/code", nil)
+		r.AddCookie(cookies[0])
+		v, err := chooseVariant(httptest.NewRecorder(), r, shortcut)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.Name != first.Name {
+			t.Fatalf("returning visitor sent to %v, then %v", first.Name, v.Name)
+		}
+	}
+
+	// Visitors whose variant was turned off are picked a new one.
+	for i := range shortcut.Variants {
+		if shortcut.Variants[i].Name == first.Name {
+			shortcut.Variants[i].Weight = 0
+		}
+	}
+	r := httptest.NewRequest("GET", "/code", nil)
+	r.AddCookie(cookies[0])
+	v, err := chooseVariant(httptest.NewRecorder(), r, shortcut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Name == first.Name {
+		t.Errorf("visitor still sent to %v after its weight was set to 0", v.Name)
+	}
+}
+
+func TestVariantsApply(t *testing.T) {
+	shortcut := model.Shortcut{ShortcutSettings: model.ShortcutSettings{Variants: model.Variants{{Name: "a", URL: "https://example.com/a", Weight: 1}}}}
+	tests := []struct {
+		branch string
+		want   bool
+	}{
+		{"", true},
+		{model.BranchDefault, true},
+		{"platform:ios", false},
+	}
+	for _, tt := range tests {
+		if got := variantsApply(shortcut, tt.branch); got != tt.want {
+			t.Errorf("variantsApply(%q) = %v, want %v", tt.branch, got, tt.want)
+		}
+	}
+	if variantsApply(model.Shortcut{}, "") {
+		t.Error("variants apply to a shortcut without variants")
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dxe/url-shortcuts-go/model"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/patrickmn/go-cache"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcrypt ignores everything after the first 72 bytes of a password.
+const (
+	minShortcutPasswordLength = 8
+	maxShortcutPasswordLength = 72
+)
+
+// unlockCookieMaxAge is how long a visitor who entered a shortcut's password
+// can follow it without entering it again.
+const unlockCookieMaxAge = 12 * time.Hour
+
+// Wrong passwords are limited per visitor IP and per shortcut, so that short
+// passwords can't be guessed online. The counts reset unlockFailureWindow after
+// the first wrong password.
+const (
+	unlockFailureWindow          = 15 * time.Minute
+	maxUnlockFailuresPerIP       = 10
+	maxUnlockFailuresPerShortcut = 100
+)
+
+// protectedPage is shown instead of redirecting when a visitor may not follow
+// a shortcut yet. It never includes the shortcut's URL.
+type protectedPage struct {
+	Host        string
+	Code        string
+	MembersOnly bool
+	Error       string
+}
+
+// validateVisibility checks a shortcut's visibility. For password protected
+// shortcuts, a new password replaces PasswordHash; otherwise the existing hash
+// is kept. Other shortcuts have no password.
+func validateVisibility(shortcut *model.Shortcut, password string, errs *validationErrors) {
+	switch shortcut.Visibility {
+	case "":
+		shortcut.Visibility = model.VisibilityPublic
+	case model.VisibilityPublic, model.VisibilityPassword, model.VisibilityMembers:
+	default:
+		errs.add("Visibility", "visibility must be one of %v, %v or %v", model.VisibilityPublic, model.VisibilityPassword, model.VisibilityMembers)
+		return
+	}
+
+	if shortcut.Visibility != model.VisibilityPassword {
+		if password != "" {
+			errs.add("Password", "passwords can only be set on %v protected shortcuts", model.VisibilityPassword)
+		}
+		shortcut.PasswordHash = ""
+		return
+	}
+
+	switch {
+	case password == "" && shortcut.PasswordHash == "":
+		errs.add("Password", "password protected shortcuts need a password")
+	case password == "":
+	case len(password) < minShortcutPasswordLength || len(password) > maxShortcutPasswordLength:
+		errs.add("Password", "password must be between %v and %v characters", minShortcutPasswordLength, maxShortcutPasswordLength)
+	default:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			errs.add("Password", "failed to hash password: %v", err)
+			return
+		}
+		shortcut.PasswordHash = string(hash)
+	}
+}
+
+// authorizeVisit checks whether the visitor may follow a shortcut. If not, it
+// responds with a page asking them to log in or enter the password.
+func (s *server) authorizeVisit(w http.ResponseWriter, r *http.Request, shortcut model.Shortcut) bool {
+	switch shortcut.Visibility {
+	case model.VisibilityMembers:
+		if s.isMember(r) {
+			return true
+		}
+		s.renderProtected(w, http.StatusUnauthorized, protectedPage{Code: shortcut.Code, MembersOnly: true})
+		return false
+	case model.VisibilityPassword:
+		if s.isUnlocked(r, shortcut) {
+			return true
+		}
+		s.renderProtected(w, http.StatusUnauthorized, protectedPage{Code: shortcut.Code})
+		return false
+	default:
+		return true
+	}
+}
+
+func (s *server) renderProtected(w http.ResponseWriter, status int, page protectedPage) {
+	page.Host = s.shortLinkHost()
+	w.Header().Set("Cache-Control", "private, no-store")
+	renderPage(w, status, "protected.html", page)
+}
+
+// isMember reports whether the request has a valid jwt cookie for an active
+// user, as issued by issueJWTToken.
+func (s *server) isMember(r *http.Request) bool {
+	token, err := jwtauth.VerifyRequest(s.tokenAuth, r, jwtauth.TokenFromCookie)
+	if err != nil {
+		return false
+	}
+	user, err := userFromClaims(token.PrivateClaims())
+	return err == nil && user.Active
+}
+
+// unlockCookie is the name of the cookie that remembers that a visitor has
+// entered a shortcut's password.
+func unlockCookie(shortcut model.Shortcut) string {
+	return fmt.Sprintf("unlock_%d", shortcut.ID)
+}
+
+// unlockToken is the value of a shortcut's unlock cookie. It depends on the
+// password hash, so changing the password locks the shortcut again.
+func (s *server) unlockToken(shortcut model.Shortcut) string {
+	mac := hmac.New(sha256.New, s.unlockKey)
+	mac.Write([]byte(strconv.Itoa(shortcut.ID) + ":" + shortcut.PasswordHash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *server) isUnlocked(r *http.Request, shortcut model.Shortcut) bool {
+	c, err := r.Cookie(unlockCookie(shortcut))
+	return err == nil && hmac.Equal([]byte(c.Value), []byte(s.unlockToken(shortcut)))
+}
+
+// handleUnlock checks the password entered for a password protected shortcut.
+// If it is right, the visitor is sent back to the shortcut, or its preview,
+// which they can now see.
+func (s *server) handleUnlock(w http.ResponseWriter, r *http.Request) {
+	shortcut, _, err := s.resolveShortcut(strings.TrimSuffix(r.URL.Path[1:], previewSuffix))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if shortcut.ID == 0 || shortcut.Visibility != model.VisibilityPassword || shortcut.StateAt(time.Now()) != model.ShortcutActive {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !s.unlockLimiter.Allow(r.RemoteAddr, shortcut.ID) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(unlockFailureWindow.Seconds())))
+		s.renderProtected(w, http.StatusTooManyRequests, protectedPage{Code: shortcut.Code, Error: "Too many wrong passwords. Try again later."})
+		return
+	}
+
+	password := r.PostFormValue("password")
+	if bcrypt.CompareHashAndPassword([]byte(shortcut.PasswordHash), []byte(password)) != nil {
+		s.unlockLimiter.Fail(r.RemoteAddr, shortcut.ID)
+		s.renderProtected(w, http.StatusUnauthorized, protectedPage{Code: shortcut.Code, Error: "That password isn't right."})
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     unlockCookie(shortcut),
+		Value:    s.unlockToken(shortcut),
+		MaxAge:   int(unlockCookieMaxAge.Seconds()),
+		SameSite: http.SameSiteLaxMode,
+		HttpOnly: true,
+		Path:     "/",
+		Secure:   s.prod,
+	})
+	http.Redirect(w, r, r.URL.RequestURI(), http.StatusSeeOther)
+}
+
+// unlockLimiter counts wrong passwords entered for protected shortcuts.
+type unlockLimiter struct {
+	failures *cache.Cache
+}
+
+func newUnlockLimiter() *unlockLimiter {
+	return &unlockLimiter{failures: cache.New(unlockFailureWindow, 5*time.Minute)}
+}
+
+// Allow reports whether the visitor may try another password for a shortcut.
+func (l *unlockLimiter) Allow(remoteAddr string, shortcutID int) bool {
+	ipKey, shortcutKey := unlockFailureKeys(remoteAddr, shortcutID)
+	return l.count(ipKey) < maxUnlockFailuresPerIP && l.count(shortcutKey) < maxUnlockFailuresPerShortcut
+}
+
+// Fail records a wrong password.
+func (l *unlockLimiter) Fail(remoteAddr string, shortcutID int) {
+	ipKey, shortcutKey := unlockFailureKeys(remoteAddr, shortcutID)
+	for _, key := range []string{ipKey, shortcutKey} {
+		if l.failures.Add(key, 1, cache.DefaultExpiration) != nil {
+			l.failures.IncrementInt(key, 1)
+		}
+	}
+}
+
+func (l *unlockLimiter) count(key string) int {
+	if v, found := l.failures.Get(key); found {
+		return v.(int)
+	}
+	return 0
+}
+
+func unlockFailureKeys(remoteAddr string, shortcutID int) (string, string) {
+	ip := remoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		ip = host
+	}
+	return "ip:" + ip, "shortcut:" + strconv.Itoa(shortcutID)
+}
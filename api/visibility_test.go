@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dxe/url-shortcuts-go/model"
+)
+
+func newProtectedShortcut(t *testing.T, s *server, code, password string) {
+	t.Helper()
+	shortcut := model.Shortcut{ShortcutSettings: model.ShortcutSettings{Code: code, URL: "https://example.com/secret", Visibility: model.VisibilityPassword}}
+	if errs := validateShortcut(&shortcut, password); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if _, err := s.store.InsertShortcut(shortcut); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func unlock(s *server, path, password, remoteAddr string) *httptest.ResponseRecorder {
+	form := url.Values{"password": {password}}
+	r := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.RemoteAddr = remoteAddr
+	return serve(s, r)
+}
+
+func TestUnlock(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t, store)
+			newProtectedShortcut(t, s, "secret", "correct horse")
+
+			w := serve(s, httptest.NewRequest("GET", "/secret", nil))
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("locked shortcut = %v", w.Code)
+			}
+			if strings.Contains(w.Body.String(), "example.com") {
+				t.Error("locked shortcut's page shows its URL")
+			}
+
+			if w := unlock(s, "/secret", "wrong", "192.0.2.1:1234"); w.Code != http.StatusUnauthorized {
+				t.Fatalf("wrong password = %v", w.Code)
+			}
+
+			w = unlock(s, "/secret?a=b", "correct horse", "192.0.2.1:1234")
+			if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/secret?a=b" {
+				t.Fatalf("right password = %v %v", w.Code, w.Header().Get("Location"))
+			}
+			cookies := w.Result().Cookies()
+			if len(cookies) != 1 {
+				t.Fatalf("cookies = %v", cookies)
+			}
+
+			r := httptest.NewRequest("GET", "/secret", nil)
+			r.AddCookie(cookies[0])
+			w = serve(s, r)
+			if w.Code != http.StatusFound || !strings.HasPrefix(w.Header().Get("Location"), "https://example.com/secret?") {
+				t.Errorf("unlocked shortcut = %v %v", w.Code, w.Header().Get("Location"))
+			}
+
+			// The cookie doesn't unlock other shortcuts.
+			newProtectedShortcut(t, s, "other", "correct horse")
+			r = httptest.NewRequest("GET", "/other", nil)
+			r.AddCookie(&http.Cookie{Name: "unlock_2", Value: cookies[0].Value})
+			if w := serve(s, r); w.Code != http.StatusUnauthorized {
+				t.Errorf("other shortcut with a copied cookie = %v", w.Code)
+			}
+		})
+	}
+}
+
+func TestUnlockLimit(t *testing.T) {
+	s := newTestServer(t, model.NewMemoryStore())
+	newProtectedShortcut(t, s, "secret", "correct horse")
+
+	for i := 0; i < maxUnlockFailuresPerIP; i++ {
+		if w := unlock(s, "/secret", "wrong", "192.0.2.1:1234"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("wrong password %v = %v", i+1, w.Code)
+		}
+	}
+	w := unlock(s, "/secret", "correct horse", "192.0.2.1:1234")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("right password after too many wrong ones = %v, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	// Other visitors aren't limited until the shortcut's own limit is reached.
+	if w := unlock(s, "/secret", "correct horse", "192.0.2.2:1234"); w.Code != http.StatusSeeOther {
+		t.Errorf("right password from another IP = %v", w.Code)
+	}
+	for i := 0; i < maxUnlockFailuresPerShortcut; i++ {
+		s.unlockLimiter.Fail("198.51.100.1:1234", 1)
+	}
+	if w := unlock(s, "/secret", "correct horse", "192.0.2.3:1234"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("right password after too many wrong ones for the shortcut = %v", w.Code)
+	}
+}
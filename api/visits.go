@@ -0,0 +1,217 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dxe/url-shortcuts-go/model"
+)
+
+// visitorHasher derives an anonymous visitor ID from an IP address, so that
+// unique visitors can be counted without storing IP addresses. The HMAC key is
+// derived from a secret and the UTC date, so a visitor's hash changes every day
+// and can't be linked across days.
+type visitorHasher struct {
+	secret []byte
+}
+
+func (h visitorHasher) Hash(remoteAddr string, t time.Time) string {
+	ip := remoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		ip = host
+	}
+
+	dayKey := hmac.New(sha256.New, h.secret)
+	dayKey.Write([]byte(t.UTC().Format("2006-01-02")))
+
+	mac := hmac.New(sha256.New, dayKey.Sum(nil))
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// visitRecorder writes visits to the store in the background. Visits are
+// queued in a bounded channel and written by a fixed pool of workers, each of
+// which batches inserts until it has batchSize visits or flushInterval has
+// passed. When the queue is full, visits are dropped rather than letting a
+// traffic spike exhaust database connections.
+type visitRecorder struct {
+	store          model.Store
+	queue          chan model.Visit
+	batchSize      int
+	flushInterval  time.Duration
+	enqueueTimeout time.Duration
+
+	// mu guards closed, so that visits aren't sent on a closed queue.
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+
+	queued  uint64
+	dropped uint64
+	flushed uint64
+	failed  uint64
+}
+
+type visitRecorderConfig struct {
+	QueueSize     int
+	Workers       int
+	BatchSize     int
+	FlushInterval time.Duration
+	// EnqueueTimeout is how long to wait for room in a full queue before
+	// dropping a visit. Zero drops immediately.
+	EnqueueTimeout time.Duration
+}
+
+// visitRecorderStats are the recorder's counters since startup.
+type visitRecorderStats struct {
+	Queued   uint64 `json:"queued"`
+	Dropped  uint64 `json:"dropped"`
+	Flushed  uint64 `json:"flushed"`
+	Failed   uint64 `json:"failed"`
+	Pending  int    `json:"pending"`
+	Capacity int    `json:"capacity"`
+}
+
+func newVisitRecorder(store model.Store, cfg visitRecorderConfig) (*visitRecorder, error) {
+	switch {
+	case cfg.QueueSize < 0:
+		return nil, fmt.Errorf("visit queue size must not be negative")
+	case cfg.Workers < 1:
+		return nil, fmt.Errorf("visit workers must be at least 1")
+	case cfg.BatchSize < 1 || cfg.BatchSize > model.MaxVisitBatchSize:
+		return nil, fmt.Errorf("visit batch size must be between 1 and %v", model.MaxVisitBatchSize)
+	case cfg.FlushInterval <= 0:
+		return nil, fmt.Errorf("visit flush interval must be positive")
+	}
+
+	v := &visitRecorder{
+		store:          store,
+		queue:          make(chan model.Visit, cfg.QueueSize),
+		batchSize:      cfg.BatchSize,
+		flushInterval:  cfg.FlushInterval,
+		enqueueTimeout: cfg.EnqueueTimeout,
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		v.wg.Add(1)
+		go v.work()
+	}
+	return v, nil
+}
+
+// Record queues a visit to be written. It never blocks for longer than the
+// enqueue timeout.
+func (v *visitRecorder) Record(visit model.Visit) {
+	if visit.Timestamp == "" {
+		visit.Timestamp = model.FormatTime(time.Now())
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if v.closed {
+		atomic.AddUint64(&v.dropped, 1)
+		return
+	}
+
+	select {
+	case v.queue <- visit:
+		atomic.AddUint64(&v.queued, 1)
+		return
+	default:
+	}
+
+	if v.enqueueTimeout > 0 {
+		timer := time.NewTimer(v.enqueueTimeout)
+		defer timer.Stop()
+		select {
+		case v.queue <- visit:
+			atomic.AddUint64(&v.queued, 1)
+			return
+		case <-timer.C:
+		}
+	}
+
+	if n := atomic.AddUint64(&v.dropped, 1); n == 1 || n%1000 == 0 {
+		log.Printf("Visit queue is full, %v visits dropped so far", n)
+	}
+}
+
+// Close stops accepting visits and waits until everything queued is written.
+func (v *visitRecorder) Close() {
+	v.mu.Lock()
+	if v.closed {
+		v.mu.Unlock()
+		return
+	}
+	v.closed = true
+	close(v.queue)
+	v.mu.Unlock()
+
+	v.wg.Wait()
+
+	stats := v.Stats()
+	log.Printf("Visit recorder stopped: %v flushed, %v failed, %v dropped", stats.Flushed, stats.Failed, stats.Dropped)
+}
+
+func (v *visitRecorder) Stats() visitRecorderStats {
+	return visitRecorderStats{
+		Queued:   atomic.LoadUint64(&v.queued),
+		Dropped:  atomic.LoadUint64(&v.dropped),
+		Flushed:  atomic.LoadUint64(&v.flushed),
+		Failed:   atomic.LoadUint64(&v.failed),
+		Pending:  len(v.queue),
+		Capacity: cap(v.queue),
+	}
+}
+
+func (v *visitRecorder) work() {
+	defer v.wg.Done()
+
+	ticker := time.NewTicker(v.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]model.Visit, 0, v.batchSize)
+	for {
+		select {
+		case visit, ok := <-v.queue:
+			if !ok {
+				v.flush(batch)
+				return
+			}
+			batch = append(batch, visit)
+			if len(batch) >= v.batchSize {
+				v.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				v.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+func (v *visitRecorder) flush(batch []model.Visit) {
+	if len(batch) == 0 {
+		return
+	}
+	if err := v.store.InsertVisits(batch); err != nil {
+		atomic.AddUint64(&v.failed, uint64(len(batch)))
+		log.Printf("Failed to record %v visits: %v", len(batch), err)
+		return
+	}
+	atomic.AddUint64(&v.flushed, uint64(len(batch)))
+}
+
+func (s *server) getVisitRecorderStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.visits.Stats())
+}
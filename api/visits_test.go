@@ -0,0 +1,211 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dxe/url-shortcuts-go/model"
+)
+
+// batchStore reports each batch of visits it's asked to insert. If release is
+// set, inserts wait until it's closed.
+type batchStore struct {
+	model.Store
+	batches chan []model.Visit
+	release chan struct{}
+}
+
+func newBatchStore(block bool) *batchStore {
+	s := &batchStore{Store: model.NewMemoryStore(), batches: make(chan []model.Visit, 100)}
+	if block {
+		s.release = make(chan struct{})
+	}
+	return s
+}
+
+func (s *batchStore) InsertVisits(visits []model.Visit) error {
+	s.batches <- append([]model.Visit(nil), visits...)
+	if s.release != nil {
+		<-s.release
+	}
+	return s.Store.InsertVisits(visits)
+}
+
+func (s *batchStore) nextBatch(t *testing.T) []model.Visit {
+	t.Helper()
+	select {
+	case batch := <-s.batches:
+		return batch
+	case <-time.After(time.Second):
+		t.Fatal("no batch was inserted")
+		return nil
+	}
+}
+
+func TestNewVisitRecorderValidatesConfig(t *testing.T) {
+	valid := visitRecorderConfig{QueueSize: 10, Workers: 1, BatchSize: 10, FlushInterval: time.Second}
+	for name, change := range map[string]func(*visitRecorderConfig){
+		"negative queue size":     func(c *visitRecorderConfig) { c.QueueSize = -1 },
+		"no workers":              func(c *visitRecorderConfig) { c.Workers = 0 },
+		"empty batches":           func(c *visitRecorderConfig) { c.BatchSize = 0 },
+		"too large batches":       func(c *visitRecorderConfig) { c.BatchSize = model.MaxVisitBatchSize + 1 },
+		"no flush interval":       func(c *visitRecorderConfig) { c.FlushInterval = 0 },
+		"negative flush interval": func(c *visitRecorderConfig) { c.FlushInterval = -time.Second },
+	} {
+		cfg := valid
+		change(&cfg)
+		if v, err := newVisitRecorder(model.NewMemoryStore(), cfg); err == nil {
+			v.Close()
+			t.Errorf("%v: no error", name)
+		}
+	}
+}
+
+func TestVisitRecorderBatchesBySize(t *testing.T) {
+	store := newBatchStore(false)
+	v, err := newVisitRecorder(store, visitRecorderConfig{QueueSize: 10, Workers: 1, BatchSize: 3, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 7; i++ {
+		v.Record(model.Visit{ShortcutID: 1})
+	}
+	for i := 0; i < 2; i++ {
+		if batch := store.nextBatch(t); len(batch) != 3 {
+			t.Errorf("batch %v has %v visits, want 3", i, len(batch))
+		}
+	}
+
+	// The last visit is only written once the recorder is closed.
+	select {
+	case batch := <-store.batches:
+		t.Fatalf("partial batch of %v visits written before the flush interval", len(batch))
+	case <-time.After(20 * time.Millisecond):
+	}
+	v.Close()
+	if batch := store.nextBatch(t); len(batch) != 1 {
+		t.Errorf("batch written on close has %v visits, want 1", len(batch))
+	}
+	if stats := v.Stats(); stats.Queued != 7 || stats.Flushed != 7 || stats.Dropped != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestVisitRecorderFlushesOnInterval(t *testing.T) {
+	store := newBatchStore(false)
+	v, err := newVisitRecorder(store, visitRecorderConfig{QueueSize: 10, Workers: 1, BatchSize: 100, FlushInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+
+	v.Record(model.Visit{ShortcutID: 1})
+	v.Record(model.Visit{ShortcutID: 1})
+	if batch := store.nextBatch(t); len(batch) != 2 {
+		t.Errorf("batch has %v visits, want 2", len(batch))
+	}
+}
+
+func TestVisitRecorderDropsWhenFull(t *testing.T) {
+	store := newBatchStore(true)
+	v, err := newVisitRecorder(store, visitRecorderConfig{QueueSize: 1, Workers: 1, BatchSize: 1, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The worker takes the first visit and waits for the store, the second
+	// one fills the queue and the third one is dropped.
+	v.Record(model.Visit{ShortcutID: 1})
+	store.nextBatch(t)
+	v.Record(model.Visit{ShortcutID: 2})
+	v.Record(model.Visit{ShortcutID: 3})
+
+	if stats := v.Stats(); stats.Queued != 2 || stats.Dropped != 1 || stats.Pending != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	close(store.release)
+	v.Close()
+	if stats := v.Stats(); stats.Flushed != 2 {
+		t.Errorf("flushed = %v, want 2", stats.Flushed)
+	}
+
+	// Visits recorded after closing are dropped.
+	v.Record(model.Visit{ShortcutID: 4})
+	if stats := v.Stats(); stats.Dropped != 2 {
+		t.Errorf("dropped after close = %v, want 2", stats.Dropped)
+	}
+}
+
+func TestVisitRecorderEnqueueTimeout(t *testing.T) {
+	store := newBatchStore(true)
+	v, err := newVisitRecorder(store, visitRecorderConfig{
+		QueueSize:      1,
+		Workers:        1,
+		BatchSize:      1,
+		FlushInterval:  time.Hour,
+		EnqueueTimeout: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Record(model.Visit{ShortcutID: 1})
+	store.nextBatch(t)
+	v.Record(model.Visit{ShortcutID: 2})
+
+	// With the queue full, a visit waits for the timeout and is dropped.
+	start := time.Now()
+	v.Record(model.Visit{ShortcutID: 3})
+	if waited := time.Since(start); waited < 100*time.Millisecond {
+		t.Errorf("dropped after %v, before the enqueue timeout", waited)
+	}
+	if stats := v.Stats(); stats.Dropped != 1 {
+		t.Errorf("dropped = %v, want 1", stats.Dropped)
+	}
+
+	// A visit is queued if room frees up before the timeout.
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		close(store.release)
+	}()
+	v.Record(model.Visit{ShortcutID: 4})
+	v.Close()
+	if stats := v.Stats(); stats.Queued != 3 || stats.Dropped != 1 || stats.Flushed != 3 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestVisitorHash(t *testing.T) {
+	h := visitorHasher{secret: []byte("secret")}
+	morning := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	evening := time.Date(2026, 3, 2, 23, 0, 0, 0, time.UTC)
+	nextDay := time.Date(2026, 3, 3, 8, 0, 0, 0, time.UTC)
+
+	hash := h.Hash("203.0.113.7:51234", morning)
+	if len(hash) != 32 {
+		t.Errorf("hash %q is %v characters long, want 32", hash, len(hash))
+	}
+	if strings.Contains(hash, "203.0.113.7") {
+		t.Errorf("hash %q contains the IP address", hash)
+	}
+	if other := h.Hash("203.0.113.7:60000", evening); other != hash {
+		t.Errorf("hash changed with the port or during the day: %q and %q", hash, other)
+	}
+	if other := h.Hash("203.0.113.7", morning); other != hash {
+		t.Errorf("hash of an address without a port = %q, want %q", other, hash)
+	}
+	if other := h.Hash("203.0.113.7:51234", nextDay); other == hash {
+		t.Error("hash didn't change the next day")
+	}
+	if other := h.Hash("203.0.113.8:51234", morning); other == hash {
+		t.Error("different IP addresses have the same hash")
+	}
+	if other := (visitorHasher{secret: []byte("other")}).Hash("203.0.113.7:51234", morning); other == hash {
+		t.Error("different secrets give the same hash")
+	}
+	// Days are in UTC.
+	if other := h.Hash("203.0.113.7:51234", evening.In(time.FixedZone("UTC+2", 2*60*60))); other != hash {
+		t.Error("hash depends on the time zone")
+	}
+}
@@ -5,6 +5,7 @@ import { Button, Form } from "react-bulma-components";
 import { Shortcut } from "./ShortcutsPage";
 import { toast } from "react-toastify";
 import { TitleBar } from "../common/TitleBar";
+import { errorMessage } from "../common/errors";
 import axios from "axios";
 
 export const EditShortcutPage = () => {
@@ -56,7 +57,7 @@ export const EditShortcutPage = () => {
       navigate("/");
     } catch (e: any) {
       console.error(e);
-      toast.error("Failed to save shortcut: " + e.response.data);
+      toast.error("Failed to save shortcut: " + errorMessage(e.response.data));
       setSaving(false);
     }
   };
@@ -5,6 +5,7 @@ import { Button, Form } from "react-bulma-components";
 import { User } from "./UsersPage";
 import { toast } from "react-toastify";
 import { TitleBar } from "../common/TitleBar";
+import { errorMessage } from "../common/errors";
 import axios from "axios";
 
 export const EditUserPage = () => {
@@ -48,7 +49,7 @@ export const EditUserPage = () => {
       navigate("/users");
     } catch (e: any) {
       console.error(e);
-      toast.error("Failed to save user: " + e.response.data);
+      toast.error("Failed to save user: " + errorMessage(e.response.data));
       setSaving(false);
     }
   };
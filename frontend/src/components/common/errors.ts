@@ -0,0 +1,13 @@
+interface FieldError {
+  field: string;
+  message: string;
+}
+
+// Formats an API error response, which is either plain text or a list of
+// field errors from validation.
+export const errorMessage = (data: any): string => {
+  if (data?.errors) {
+    return data.errors.map((e: FieldError) => e.message).join("; ");
+  }
+  return data;
+};